@@ -0,0 +1,42 @@
+package filesystem
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// writableFile is the subset of *os.File used when writing files
+type writableFile interface {
+	io.Writer
+	io.Closer
+	Name() string
+	Sync() error
+}
+
+// Operating system calls that tests replace to simulate conditions that are hard to
+// reproduce on a real filesystem (hung network mounts, short writes, etc.)
+var (
+	osOpenFile = func(name string, flag int, perm os.FileMode) (writableFile, error) {
+		f, err := os.OpenFile(name, flag, perm)
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+	osOpen = func(name string) (io.ReadCloser, error) {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+	osStat     = os.Stat
+	osTempFile = func(dir, pattern string) (writableFile, error) {
+		f, err := ioutil.TempFile(dir, pattern)
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+)
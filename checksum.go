@@ -0,0 +1,196 @@
+package filesystem
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ChecksumDirectory returns the SHA-256 checksum (see GetFileSHA256Checksum) of each regular file directly inside
+// path, keyed by file name
+// Files are always read, even when the checksum cache is enabled
+// Subdirectories, symlinks, and special files are skipped
+func ChecksumDirectory(path string) (map[string]string, error) {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return nil, err
+	}
+	var fields = logrus.Fields{
+		"directory": logPath(path),
+	}
+
+	logger.WithFields(fields).Debug("Computing checksums of directory contents")
+	defer logDuration(fields, time.Now(), "Directory contents checksums")
+	var checksums = map[string]string{}
+	err = IterateDirectory(path, func(info os.FileInfo) error {
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		checksum, err := fileSHA256Checksum(filepath.Join(path, info.Name()))
+		if err != nil {
+			return err
+		}
+		checksums[info.Name()] = checksum
+		return nil
+	})
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to compute checksums of directory contents")
+		return nil, err
+	}
+	fields["files"] = len(checksums)
+	logger.WithFields(fields).Debug("Computed checksums of directory contents")
+	return checksums, nil
+}
+
+// GetDirectoryChecksum gets a SHA-256 checksum covering the structure and contents of the tree at root as a hex string
+// Two trees have the same checksum when they contain the same relative paths with the same types, file contents,
+// and symlink targets; modes and modification times are not included
+func GetDirectoryChecksum(root string) (string, error) {
+	return GetDirectoryChecksumFiltered(root, nil)
+}
+
+// GetDirectoryChecksumFiltered gets a checksum of the tree at root like GetDirectoryChecksum, skipping entries that
+// match any of the ignore patterns (see filepath.Match)
+// Patterns are matched against both the slash-separated path relative to root and the entry's name, so .git
+// ignores every .git directory while build/*.o only ignores object files directly inside build; ignored directories
+// are skipped entirely
+func GetDirectoryChecksumFiltered(root string, ignore []string) (string, error) {
+	var err error
+	root, err = BuildAbsolutePathFromHome(root)
+	if err != nil {
+		return "", err
+	}
+	var fields = logrus.Fields{
+		"directory": logPath(root),
+		"ignore":    ignore,
+	}
+
+	logger.WithFields(fields).Debug("Computing directory checksum")
+	defer logDuration(fields, time.Now(), "Directory checksum")
+	if !isDirectory(root) {
+		err = errors.New(root + " is not a directory")
+		logger.WithFields(fields).Warn("Failed to compute directory checksum")
+		return "", err
+	}
+
+	hasher := sha256.New()
+	// filepath.Walk visits entries in lexical order, so the checksum doesn't depend on directory ordering
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || path == root {
+			return err
+		}
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		ignored, err := matchesAny(ignore, relPath, info.Name())
+		if err != nil {
+			return err
+		}
+		if ignored {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		switch {
+		case info.IsDir():
+			io.WriteString(hasher, "d "+relPath+"\n")
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			io.WriteString(hasher, "l "+relPath+" "+target+"\n")
+		case info.Mode().IsRegular():
+			checksum, err := fileSHA256Checksum(path)
+			if err != nil {
+				return err
+			}
+			io.WriteString(hasher, "f "+relPath+" "+checksum+"\n")
+		}
+		return nil
+	})
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to compute directory checksum")
+		return "", err
+	}
+	var checksum = hex.EncodeToString(hasher.Sum(nil))
+	fields["checksum"] = checksum
+	logger.WithFields(fields).Debug("Computed directory checksum")
+	return checksum, nil
+}
+
+// GetFileCRC32 gets the CRC-32 (IEEE) checksum of the file
+// Much cheaper to compute than GetFileSHA256Checksum, but only suitable for detecting accidental changes
+func GetFileCRC32(path string) (uint32, error) {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return 0, err
+	}
+	var fields = logrus.Fields{
+		"path": logPath(path),
+	}
+	defer logDuration(fields, time.Now(), "CRC-32")
+
+	if !isFile(path) {
+		err = errors.New(path + " is not a file")
+		logger.WithFields(fields).Warn("Failed to retrieve file CRC-32")
+		return 0, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to retrieve file CRC-32")
+		return 0, err
+	}
+	defer f.Close()
+
+	hasher := crc32.NewIEEE()
+	if _, err = io.Copy(hasher, f); err != nil {
+		logger.WithFields(fields).Warn("Failed to retrieve file CRC-32")
+		return 0, err
+	}
+	fields["crc32"] = hasher.Sum32()
+	logger.WithFields(fields).Debug("Computed file CRC-32")
+	return hasher.Sum32(), nil
+}
+
+// fileSHA256Checksum reads path and returns its SHA-256 checksum as a hex string
+// The checksum cache is never consulted, so this is what verification within the package uses
+func fileSHA256Checksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	if _, err = io.Copy(hasher, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// matchesAny reports whether any of the names match any of patterns
+func matchesAny(patterns []string, names ...string) (bool, error) {
+	for _, pattern := range patterns {
+		for _, name := range names {
+			matched, err := filepath.Match(pattern, name)
+			if err != nil || matched {
+				return matched, err
+			}
+		}
+	}
+	return false, nil
+}
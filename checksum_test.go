@@ -0,0 +1,141 @@
+package filesystem
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestChecksumDirectory(t *testing.T) {
+	color.Yellow("Testing directory content checksums")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	WriteFile(tempDir+"/a.txt", []byte("first"), 0644)
+	WriteFile(tempDir+"/b.bin", []byte{0, 1, 2, 3}, 0644)
+	CreateDirectory(tempDir + "/sub")
+	WriteFile(tempDir+"/sub/c.txt", []byte("nested"), 0644)
+
+	checksums, err := ChecksumDirectory(tempDir)
+	if err != nil {
+		t.Fatal("ChecksumDirectory failed:", err)
+	}
+	if len(checksums) != 2 {
+		t.Error("Unexpected number of checksums:", "Got:", checksums, "Wanted:", 2)
+	}
+	for _, name := range []string{"a.txt", "b.bin"} {
+		if expected, _ := GetFileSHA256Checksum(tempDir + "/" + name); checksums[name] != expected {
+			t.Error("Checksum doesn't match:", name, "Got:", checksums[name], "Wanted:", expected)
+		}
+	}
+	if _, err := ChecksumDirectory(tempDir + "/dir-dne"); err == nil {
+		t.Error("ChecksumDirectory succeeded with non-existent directory")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestGetDirectoryChecksum(t *testing.T) {
+	color.Yellow("Testing directory checksums")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var first = tempDir + "/first"
+	var second = tempDir + "/second"
+	for _, dir := range []string{first, second} {
+		CreateDirectory(dir + "/src")
+		WriteFile(dir+"/src/main.go", []byte("test"), 0644)
+	}
+	firstSum, err := GetDirectoryChecksum(first)
+	if err != nil {
+		t.Fatal("GetDirectoryChecksum failed:", first, err)
+	}
+	if secondSum, err := GetDirectoryChecksum(second); err != nil || secondSum != firstSum {
+		t.Error("Identical trees have different checksums:", firstSum, secondSum, err)
+	}
+	CreateDirectory(second + "/empty")
+	if secondSum, _ := GetDirectoryChecksum(second); secondSum == firstSum {
+		t.Error("Adding a directory didn't change the checksum")
+	}
+	if _, err := GetDirectoryChecksum(tempDir + "/dir-dne"); err == nil {
+		t.Error("GetDirectoryChecksum succeeded with non-existent directory")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestGetDirectoryChecksumFiltered(t *testing.T) {
+	color.Yellow("Testing filtered directory checksums")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var ignore = []string{".git", "node_modules", "*.log"}
+	CreateDirectory(tempDir + "/.git")
+	CreateDirectory(tempDir + "/web/node_modules")
+	WriteFile(tempDir+"/.git/HEAD", []byte("test"), 0644)
+	WriteFile(tempDir+"/web/node_modules/dep.js", []byte("test"), 0644)
+	WriteFile(tempDir+"/web/build.log", []byte("test"), 0644)
+	WriteFile(tempDir+"/web/index.js", []byte("test"), 0644)
+
+	before, err := GetDirectoryChecksumFiltered(tempDir, ignore)
+	if err != nil {
+		t.Fatal("GetDirectoryChecksumFiltered failed:", tempDir, err)
+	}
+	WriteFile(tempDir+"/.git/HEAD", []byte("changed"), 0644)
+	WriteFile(tempDir+"/web/node_modules/dep.js", []byte("changed"), 0644)
+	WriteFile(tempDir+"/web/node_modules/new.js", []byte("changed"), 0644)
+	DeleteFile(tempDir + "/web/build.log")
+	if after, err := GetDirectoryChecksumFiltered(tempDir, ignore); err != nil || after != before {
+		t.Error("Changing ignored files changed the checksum:", before, after, err)
+	}
+
+	WriteFile(tempDir+"/web/index.js", []byte("changed"), 0644)
+	if after, err := GetDirectoryChecksumFiltered(tempDir, ignore); err != nil || after == before {
+		t.Error("Changing a tracked file didn't change the checksum:", before, after, err)
+	}
+	if _, err := GetDirectoryChecksumFiltered(tempDir, []string{"["}); err == nil {
+		t.Error("GetDirectoryChecksumFiltered succeeded with a malformed pattern")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestGetFileCRC32(t *testing.T) {
+	color.Yellow("Testing CRC-32 checksums")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var testData = []struct {
+		contents string
+		expected uint32
+	}{
+		{"123456789", 0xcbf43926},
+		{"", 0},
+		{"The quick brown fox jumps over the lazy dog", 0x414fa339},
+	}
+	var testFile = tempDir + "/test.file"
+	for _, test := range testData {
+		WriteFile(testFile, []byte(test.contents), 0644)
+		if got, err := GetFileCRC32(testFile); err != nil || got != test.expected {
+			t.Errorf("GetFileCRC32 failed: %q Got: %#08x Wanted: %#08x %v", test.contents, got, test.expected, err)
+		}
+	}
+	if _, err := GetFileCRC32(tempDir); err == nil {
+		t.Error("GetFileCRC32 succeeded with a directory")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
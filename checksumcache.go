@@ -0,0 +1,71 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// checksumCacheEntry records a checksum along with the file metadata it was computed for
+type checksumCacheEntry struct {
+	size     int64
+	modTime  time.Time
+	checksum string
+}
+
+var checksumCache map[string]checksumCacheEntry
+var checksumCacheLock sync.Mutex
+
+// DisableChecksumCache turns off the checksum cache and discards any cached checksums
+func DisableChecksumCache() {
+	checksumCacheLock.Lock()
+	defer checksumCacheLock.Unlock()
+	checksumCache = nil
+}
+
+// EnableChecksumCache caches the results of GetFileSHA256Checksum in memory
+// Cached checksums are reused until the file's size or modification time changes, so a file rewritten
+// with the same size within the filesystem's timestamp resolution can return a stale checksum
+func EnableChecksumCache() {
+	checksumCacheLock.Lock()
+	defer checksumCacheLock.Unlock()
+	if checksumCache == nil {
+		checksumCache = map[string]checksumCacheEntry{}
+	}
+}
+
+// cachedChecksum returns the cached checksum of path if the cache is enabled and info still matches it
+func cachedChecksum(path string, info os.FileInfo) (string, bool) {
+	checksumCacheLock.Lock()
+	defer checksumCacheLock.Unlock()
+	if checksumCache == nil {
+		return "", false
+	}
+	entry, ok := checksumCache[checksumCacheKey(path)]
+	if !ok || entry.size != info.Size() || !entry.modTime.Equal(info.ModTime()) {
+		return "", false
+	}
+	return entry.checksum, true
+}
+
+// storeChecksum caches the checksum of path if the cache is enabled
+func storeChecksum(path string, info os.FileInfo, checksum string) {
+	checksumCacheLock.Lock()
+	defer checksumCacheLock.Unlock()
+	if checksumCache == nil {
+		return
+	}
+	checksumCache[checksumCacheKey(path)] = checksumCacheEntry{info.Size(), info.ModTime(), checksum}
+}
+
+// checksumCacheKey canonicalizes path so different spellings of the same file share a cache entry
+func checksumCacheKey(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return path
+}
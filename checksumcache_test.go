@@ -0,0 +1,95 @@
+package filesystem
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+func TestChecksumCache(t *testing.T) {
+	color.Yellow("Testing the checksum cache")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	EnableChecksumCache()
+	defer DisableChecksumCache()
+
+	var testFile = tempDir + "/test.file"
+	var past = time.Now().Add(-time.Hour)
+	WriteFile(testFile, []byte("test"), 0644)
+	os.Chtimes(testFile, past, past)
+	var testSum = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	if c, err := GetFileSHA256Checksum(testFile); err != nil || c != testSum {
+		t.Error("GetFileSHA256Checksum failed:", "Got:", c, "Wanted:", testSum, err)
+	}
+
+	// Same size and modification time, so the cached checksum is expected even though the contents changed
+	WriteFile(testFile, []byte("tset"), 0644)
+	os.Chtimes(testFile, past, past)
+	if c, err := GetFileSHA256Checksum(testFile); err != nil || c != testSum {
+		t.Error("Checksum cache was not used for an unchanged file:", "Got:", c, "Wanted:", testSum, err)
+	}
+
+	var tsetSum = "18ea285983df355f3024e412fb46ad6cbd98a7ffe6872e26612e35f38aa39c41"
+	os.Chtimes(testFile, time.Now(), time.Now())
+	if c, err := GetFileSHA256Checksum(testFile); err != nil || c != tsetSum {
+		t.Error("Checksum was not recomputed after the modification time changed:", "Got:", c, "Wanted:", tsetSum, err)
+	}
+
+	DisableChecksumCache()
+	WriteFile(testFile, []byte("test"), 0644)
+	os.Chtimes(testFile, time.Now(), time.Now())
+	if c, err := GetFileSHA256Checksum(testFile); err != nil || c != testSum {
+		t.Error("GetFileSHA256Checksum failed with the cache disabled:", "Got:", c, "Wanted:", testSum, err)
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestChecksumCacheVerification(t *testing.T) {
+	color.Yellow("Testing verification with the checksum cache enabled")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	EnableChecksumCache()
+	defer DisableChecksumCache()
+
+	// Leave a stale entry in the cache: same size and modification time, different contents
+	var testFile = tempDir + "/test.file"
+	var past = time.Now().Add(-time.Hour)
+	WriteFile(testFile, []byte("test"), 0644)
+	os.Chtimes(testFile, past, past)
+	GetFileSHA256Checksum(testFile)
+	WriteFile(testFile, []byte("tset"), 0644)
+	os.Chtimes(testFile, past, past)
+
+	if written, err := WriteFileIfChanged(testFile, []byte("test"), 0644); err != nil || !written {
+		t.Error("WriteFileIfChanged trusted a stale cached checksum:", "Got:", written, err, "Wanted:", true)
+	}
+	if c, _ := LoadFileString(testFile); c != "test" {
+		t.Error("File has unexpected contents:", "Got:", c, "Wanted:", "test")
+	}
+
+	CreateDirectory(tempDir + "/a")
+	CreateDirectory(tempDir + "/b")
+	WriteFile(tempDir+"/a/test.file", []byte("test"), 0644)
+	WriteFile(tempDir+"/b/test.file", []byte("test"), 0644)
+	os.Chtimes(tempDir+"/b/test.file", past, past)
+	GetFileSHA256Checksum(tempDir + "/b/test.file")
+	WriteFile(tempDir+"/b/test.file", []byte("tset"), 0644)
+	os.Chtimes(tempDir+"/b/test.file", past, past)
+	if equal, _, err := DirectoriesEqual(tempDir+"/a", tempDir+"/b", true); err != nil || equal {
+		t.Error("DirectoriesEqual trusted a stale cached checksum:", "Got:", equal, err, "Wanted:", false)
+	}
+	color.Yellow("Test Complete")
+	println()
+}
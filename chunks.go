@@ -0,0 +1,142 @@
+package filesystem
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// chunkNumber matches the last run of digits in a chunk file name
+var chunkNumber = regexp.MustCompile(`(\d+)\D*$`)
+
+// AssembleChunks concatenates the numbered chunk files in chunkDir into outFile
+// A chunk's number is the last run of digits in its file name, which may only be followed by non-digits (i.e.
+// chunk-10 or part-0010.bin); chunks are ordered numerically (chunk-2 before chunk-10), with equal numbers ordered
+// by name. Files without a number (i.e. a manifest) and subdirectories are skipped
+// The SHA-256 checksum is computed while assembling; on mismatch an *ErrChecksumMismatch is returned and no output is left behind
+func AssembleChunks(chunkDir, outFile, expectedSHA256 string) error {
+	var err error
+	chunkDir, err = BuildAbsolutePathFromHome(chunkDir)
+	if err != nil {
+		return err
+	}
+	outFile, err = BuildAbsolutePathFromHome(outFile)
+	if err != nil {
+		return err
+	}
+	var fields = logrus.Fields{
+		"directory": logPath(chunkDir),
+		"file":      logPath(outFile),
+		"expected":  expectedSHA256,
+	}
+
+	logger.WithFields(fields).Debug("Assembling chunks")
+	chunks, err := sortedChunks(chunkDir)
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to assemble chunks")
+		return err
+	}
+	fields["chunks"] = len(chunks)
+
+	// Assemble into a temp file next to outFile so a failed or mismatched assembly never leaves partial output
+	tmp, err := ioutil.TempFile(filepath.Dir(outFile), "."+filepath.Base(outFile)+".partial-")
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to assemble chunks")
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	hasher := sha256.New()
+	var out = io.MultiWriter(tmp, hasher)
+	for _, chunk := range chunks {
+		if err = appendFile(out, chunk); err != nil {
+			break
+		}
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to assemble chunks")
+		return err
+	}
+
+	checksum := hex.EncodeToString(hasher.Sum(nil))
+	if !strings.EqualFold(checksum, expectedSHA256) {
+		fields["checksum"] = checksum
+		logger.WithFields(fields).Warn("Assembled file checksum does not match")
+		return &ErrChecksumMismatch{Path: outFile, Expected: expectedSHA256, Actual: checksum}
+	}
+	if err = os.Chmod(tmp.Name(), fileMode(0)); err == nil {
+		err = os.Rename(tmp.Name(), outFile)
+	}
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to assemble chunks")
+		return err
+	}
+	logger.WithFields(fields).Debug("Chunks assembled successfully")
+	return nil
+}
+
+// appendFile copies the contents of path to w
+func appendFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// sortedChunks returns the paths of the chunk files in dir in numerical order, skipping files without a number
+func sortedChunks(dir string) ([]string, error) {
+	type chunk struct {
+		path   string
+		number int
+	}
+	var chunks = []chunk{}
+	err := IterateDirectory(dir, func(info os.FileInfo) error {
+		if info.IsDir() {
+			return nil
+		}
+		var match = chunkNumber.FindStringSubmatch(info.Name())
+		if match == nil {
+			return nil
+		}
+		n, err := strconv.Atoi(match[1])
+		if err != nil {
+			return errors.New(info.Name() + " has a chunk number that is out of range")
+		}
+		chunks = append(chunks, chunk{filepath.Join(dir, info.Name()), n})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) == 0 {
+		return nil, &ErrEmptyDirectory{Path: dir}
+	}
+
+	sort.SliceStable(chunks, func(i, j int) bool {
+		if chunks[i].number != chunks[j].number {
+			return chunks[i].number < chunks[j].number
+		}
+		return chunks[i].path < chunks[j].path
+	})
+	var paths = make([]string, len(chunks))
+	for i, c := range chunks {
+		paths[i] = c.path
+	}
+	return paths, nil
+}
@@ -0,0 +1,69 @@
+package filesystem
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strconv"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestAssembleChunks(t *testing.T) {
+	color.Yellow("Testing chunk assembly")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	// Split a file into enough chunks that lexical ordering would be wrong (chunk-10 before chunk-2)
+	var original = []byte{}
+	for i := 0; i < 1000; i++ {
+		original = append(original, []byte(strconv.Itoa(i)+"\n")...)
+	}
+	var source = tempDir + "/source.file"
+	var chunkDir = tempDir + "/chunks"
+	WriteFile(source, original, 0644)
+	CreateDirectory(chunkDir)
+	var chunkSize = len(original)/12 + 1
+	for i := 0; i*chunkSize < len(original); i++ {
+		var end = (i + 1) * chunkSize
+		if end > len(original) {
+			end = len(original)
+		}
+		WriteFile(chunkDir+"/chunk-"+strconv.Itoa(i), original[i*chunkSize:end], 0644)
+	}
+	checksum, _ := GetFileSHA256Checksum(source)
+
+	// Files without a chunk number are skipped
+	WriteFile(chunkDir+"/manifest.txt", []byte("manifest"), 0644)
+
+	var outFile = tempDir + "/assembled.file"
+	if err := AssembleChunks(chunkDir, outFile, checksum); err != nil {
+		t.Fatal("AssembleChunks failed:", err)
+	}
+	if c, err := LoadFileBytes(outFile); err != nil || !bytes.Equal(c, original) {
+		t.Error("Assembled file doesn't match the original")
+	}
+
+	// Tamper with a chunk
+	DeleteFile(outFile)
+	WriteFile(chunkDir+"/chunk-3", []byte("tampered"), 0644)
+	err = AssembleChunks(chunkDir, outFile, checksum)
+	if _, ok := err.(*ErrChecksumMismatch); !ok {
+		t.Error("AssembleChunks should return *ErrChecksumMismatch for a tampered chunk:", "Got:", err)
+	}
+	if CheckExists(outFile) {
+		t.Error("Partial output was left behind:", outFile)
+	}
+	if c, _ := GetDirectoryContents(tempDir); len(c) != 2 {
+		t.Error("Temporary assembly files were left behind:", c)
+	}
+
+	if err := AssembleChunks(tempDir+"/dne", outFile, checksum); err == nil {
+		t.Error("AssembleChunks succeeded with non-existent chunk directory")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
@@ -0,0 +1,65 @@
+package filesystem
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ReadFileDecoded opens path and passes its contents to decode along with v
+// This lets callers choose the format (i.e. func(r io.Reader, v interface{}) error { return json.NewDecoder(r).Decode(v) })
+func ReadFileDecoded(path string, v interface{}, decode func(io.Reader, interface{}) error) error {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return err
+	}
+	var fields = logrus.Fields{
+		"file": logPath(path),
+	}
+
+	logger.WithFields(fields).Debug("Decoding file")
+	if !isFile(path) {
+		err = errors.New(path + " is not a file")
+		logger.WithFields(fields).Warn("Failed to decode file")
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to decode file")
+		return err
+	}
+	defer f.Close()
+
+	if err = decode(f, v); err != nil {
+		logger.WithFields(fields).Warn("Failed to decode file")
+		return err
+	}
+	logger.WithFields(fields).Debug("File decoded successfully")
+	return nil
+}
+
+// WriteFileEncoded encodes v with encode and atomically writes the result to path (see WriteFileAtomic)
+// If encoding fails, path is left untouched
+// A mode of 0 uses the default file mode (see SetDefaultFileMode)
+func WriteFileEncoded(path string, v interface{}, encode func(io.Writer, interface{}) error, mode os.FileMode) error {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return err
+	}
+	var fields = logrus.Fields{
+		"file": logPath(path),
+	}
+
+	logger.WithFields(fields).Debug("Encoding file")
+	var buf bytes.Buffer
+	if err = encode(&buf, v); err != nil {
+		logger.WithFields(fields).Warn("Failed to encode file")
+		return err
+	}
+	return WriteFileAtomic(path, buf.Bytes(), mode)
+}
@@ -0,0 +1,58 @@
+package filesystem
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestFileEncoding(t *testing.T) {
+	color.Yellow("Testing pluggable file encoding")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	type config struct {
+		Name    string   `json:"name"`
+		Workers int      `json:"workers"`
+		Tags    []string `json:"tags"`
+	}
+	var encode = func(w io.Writer, v interface{}) error {
+		return json.NewEncoder(w).Encode(v)
+	}
+	var decode = func(r io.Reader, v interface{}) error {
+		return json.NewDecoder(r).Decode(v)
+	}
+
+	var configFile = tempDir + "/config.json"
+	var expected = config{"worker", 4, []string{"a", "b"}}
+	if err := WriteFileEncoded(configFile, expected, encode, 0644); err != nil {
+		t.Error("WriteFileEncoded failed:", configFile, err)
+	}
+	var got config
+	if err := ReadFileDecoded(configFile, &got, decode); err != nil || !reflect.DeepEqual(got, expected) {
+		t.Error("Decoded value doesn't match what was encoded:", "Got:", got, "Wanted:", expected, err)
+	}
+
+	if err := WriteFileEncoded(configFile, make(chan int), encode, 0644); err == nil {
+		t.Error("WriteFileEncoded succeeded with a value that can't be encoded")
+	}
+	if err := ReadFileDecoded(configFile, &got, decode); err != nil || !reflect.DeepEqual(got, expected) {
+		t.Error("A failed encode changed the existing file:", err)
+	}
+	WriteFile(configFile, []byte("{not json"), 0644)
+	if err := ReadFileDecoded(configFile, &got, decode); err == nil {
+		t.Error("ReadFileDecoded succeeded with malformed contents")
+	}
+	if err := ReadFileDecoded(tempDir+"/file-dne", &got, decode); err == nil {
+		t.Error("ReadFileDecoded succeeded with non-existent file")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
@@ -0,0 +1,529 @@
+package filesystem
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// CopyReport summarizes the work done by CopyDirectory
+// Skipped and Failed hold paths relative to the source directory
+type CopyReport struct {
+	FilesCopied int
+	DirsCreated int
+	BytesCopied int64
+	Skipped     []string
+	Failed      []string
+}
+
+// CopyFileSparse copies the contents of src to dst like CopyFile, preserving holes in sparse files
+// Blocks of zeros are skipped with a seek rather than written, so copies of disk images and other sparse
+// files don't take up more space on disk than their source (on filesystems that support sparse files)
+func CopyFileSparse(src, dst string) error {
+	var err error
+	src, err = BuildAbsolutePathFromHome(src)
+	if err != nil {
+		return err
+	}
+	dst, err = BuildAbsolutePathFromHome(dst)
+	if err != nil {
+		return err
+	}
+	var fields = logrus.Fields{
+		"source":      logPath(src),
+		"destination": logPath(dst),
+	}
+
+	logger.WithFields(fields).Debug("Copying sparse file")
+	defer logDuration(fields, time.Now(), "Sparse copy")
+	if err = copyFileSparse(src, dst); err != nil {
+		logger.WithFields(fields).Warn("Failed to copy file")
+		return err
+	}
+	logger.WithFields(fields).Debug("File copied successfully")
+	return nil
+}
+
+// CopyFileThrottled copies the contents of src to dst like CopyFile, keeping throughput under rateBytesPerSec
+// A rate of 0 copies at full speed
+func CopyFileThrottled(src, dst string, rateBytesPerSec int64) error {
+	var err error
+	src, err = BuildAbsolutePathFromHome(src)
+	if err != nil {
+		return err
+	}
+	dst, err = BuildAbsolutePathFromHome(dst)
+	if err != nil {
+		return err
+	}
+	var fields = logrus.Fields{
+		"source":      logPath(src),
+		"destination": logPath(dst),
+		"rate":        rateBytesPerSec,
+	}
+
+	logger.WithFields(fields).Debug("Copying file with throttling")
+	defer logDuration(fields, time.Now(), "Throttled copy")
+	if rateBytesPerSec < 0 {
+		err = errors.New("rate must not be negative")
+	} else if rateBytesPerSec == 0 {
+		_, err = copyFile(src, dst)
+	} else {
+		_, err = copyFileWith(src, dst, func(r io.Reader) io.Reader {
+			return &throttledReader{reader: r, rate: rateBytesPerSec}
+		})
+	}
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to copy file")
+		return err
+	}
+	logger.WithFields(fields).Debug("File copied successfully")
+	return nil
+}
+
+// CopyFileVerified copies the contents of src to dst like CopyFile, then confirms the copy by checksum
+// The source checksum is computed during the copy and compared against a fresh read of dst; on mismatch
+// dst is removed and an *ErrChecksumMismatch is returned
+func CopyFileVerified(src, dst string) error {
+	var err error
+	src, err = BuildAbsolutePathFromHome(src)
+	if err != nil {
+		return err
+	}
+	dst, err = BuildAbsolutePathFromHome(dst)
+	if err != nil {
+		return err
+	}
+	var fields = logrus.Fields{
+		"source":      logPath(src),
+		"destination": logPath(dst),
+	}
+
+	logger.WithFields(fields).Debug("Copying file with verification")
+	defer logDuration(fields, time.Now(), "Verified copy")
+	hasher := sha256.New()
+	_, err = copyFileWith(src, dst, func(r io.Reader) io.Reader {
+		return io.TeeReader(r, hasher)
+	})
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to copy file")
+		return err
+	}
+
+	var expected = hex.EncodeToString(hasher.Sum(nil))
+	actual, err := fileSHA256Checksum(dst)
+	if err == nil && actual != expected {
+		err = &ErrChecksumMismatch{Path: dst, Expected: expected, Actual: actual}
+	}
+	if err != nil {
+		os.Remove(dst)
+		logger.WithFields(fields).Warn("Failed to verify copied file")
+		return err
+	}
+	logger.WithFields(fields).Debug("File copied and verified successfully")
+	return nil
+}
+
+// CopyPermissions applies the mode (including setuid/setgid/sticky bits) and ownership of src to dst
+// Ownership is only copied where supported; if the platform doesn't support it or the process lacks the
+// privileges to change it, only the mode is copied
+func CopyPermissions(src, dst string) error {
+	var err error
+	src, err = BuildAbsolutePathFromHome(src)
+	if err != nil {
+		return err
+	}
+	dst, err = BuildAbsolutePathFromHome(dst)
+	if err != nil {
+		return err
+	}
+	var fields = logrus.Fields{
+		"source":      logPath(src),
+		"destination": logPath(dst),
+	}
+
+	logger.WithFields(fields).Debug("Copying permissions")
+	var mode os.FileMode
+	info, err := os.Stat(src)
+	if err == nil {
+		mode = info.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+		fields["mode"] = mode
+		err = os.Chmod(dst, mode)
+	}
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to copy permissions")
+		return err
+	}
+
+	// chown clears setuid/setgid on some platforms, so the mode is reapplied afterward
+	if err = copyOwnership(info, dst); err == nil {
+		err = os.Chmod(dst, mode)
+	} else if err == ErrUnsupportedPlatform || os.IsPermission(err) {
+		logger.WithFields(fields).Info("Ownership could not be copied; copied mode only")
+		err = nil
+	}
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to copy permissions")
+		return err
+	}
+	logger.WithFields(fields).Debug("Permissions copied successfully")
+	return nil
+}
+
+// CopyTruncate copies src to rotatedDst, then truncates src to zero length in place (logrotate's copytruncate)
+// src keeps its inode, so processes with it open keep writing to the same file without being restarted;
+// writers should open it with O_APPEND, or they'll continue at their old offset and leave a hole at the start
+// Anything written to src between the copy and the truncate is lost
+func CopyTruncate(src, rotatedDst string) error {
+	var err error
+	src, err = BuildAbsolutePathFromHome(src)
+	if err != nil {
+		return err
+	}
+	rotatedDst, err = BuildAbsolutePathFromHome(rotatedDst)
+	if err != nil {
+		return err
+	}
+	var fields = logrus.Fields{
+		"source":      logPath(src),
+		"destination": logPath(rotatedDst),
+	}
+
+	logger.WithFields(fields).Debug("Copying and truncating file")
+	defer logDuration(fields, time.Now(), "Copy truncate")
+	if _, err = copyFile(src, rotatedDst); err != nil {
+		logger.WithFields(fields).Warn("Failed to copy file")
+		return err
+	}
+	if err = os.Truncate(src, 0); err != nil {
+		logger.WithFields(fields).Warn("Failed to truncate file")
+		return err
+	}
+	logger.WithFields(fields).Debug("File copied and truncated successfully")
+	return nil
+}
+
+// dirTime records the modification time to apply to a copied directory
+type dirTime struct {
+	path    string
+	modTime time.Time
+}
+
+// CopyDirectory copies the directory tree at src to dst
+// Regular files and directories are copied with their permissions; other entries (symlinks, devices, etc.) are skipped
+// Copying continues past individual failures; the first error encountered is returned along with the report
+func CopyDirectory(src, dst string) (*CopyReport, error) {
+	return copyDirectory(src, dst, false)
+}
+
+// CopyDirectoryPreserveTimes copies the directory tree at src to dst like CopyDirectory, then sets the
+// modification time of each destination directory to match its source
+func CopyDirectoryPreserveTimes(src, dst string) (*CopyReport, error) {
+	return copyDirectory(src, dst, true)
+}
+
+// copyDirectory copies the directory tree at src to dst, optionally preserving directory modification times
+func copyDirectory(src, dst string, preserveTimes bool) (*CopyReport, error) {
+	var err error
+	src, err = BuildAbsolutePathFromHome(src)
+	if err != nil {
+		return nil, err
+	}
+	dst, err = BuildAbsolutePathFromHome(dst)
+	if err != nil {
+		return nil, err
+	}
+	var fields = logrus.Fields{
+		"source":      logPath(src),
+		"destination": logPath(dst),
+	}
+	var report = &CopyReport{
+		Skipped: []string{},
+		Failed:  []string{},
+	}
+
+	logger.WithFields(fields).Debug("Copying directory")
+	if !isDirectory(src) {
+		err = errors.New(src + " is not a directory")
+		logger.WithFields(fields).Warn("Failed to copy directory")
+		return report, err
+	}
+	// Walking into dst while it's being created would recurse until paths grow too long
+	if _, err = pathDepth(resolvePath(src), resolvePath(dst)); err == nil {
+		err = errors.New("cannot copy " + src + " into itself (" + dst + ")")
+		logger.WithFields(fields).Warn("Failed to copy directory")
+		return report, err
+	}
+
+	// Directories are recorded in walk order (parents first) so their times can be applied bottom-up
+	var dirTimes = []dirTime{}
+	var firstErr error
+	var fail = func(relPath string, err error) {
+		report.Failed = append(report.Failed, relPath)
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	err = filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		relPath, relErr := filepath.Rel(src, path)
+		if relErr != nil {
+			return relErr
+		}
+		if err != nil {
+			fail(relPath, err)
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		var target = filepath.Join(dst, relPath)
+
+		switch {
+		case info.IsDir():
+			if !isDirectory(target) {
+				if err := os.MkdirAll(target, info.Mode().Perm()); err != nil {
+					fail(relPath, err)
+					return filepath.SkipDir
+				}
+				report.DirsCreated++
+			}
+			dirTimes = append(dirTimes, dirTime{target, info.ModTime()})
+		case info.Mode().IsRegular():
+			n, err := copyFile(path, target)
+			if err != nil {
+				fail(relPath, err)
+				return nil
+			}
+			report.FilesCopied++
+			report.BytesCopied += n
+		default:
+			report.Skipped = append(report.Skipped, relPath)
+		}
+		return nil
+	})
+	if preserveTimes {
+		// Applied last and deepest first, since writing into a directory updates its modification time
+		for i := len(dirTimes) - 1; i >= 0; i-- {
+			if err := os.Chtimes(dirTimes[i].path, dirTimes[i].modTime, dirTimes[i].modTime); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	if err == nil {
+		err = firstErr
+	}
+
+	fields["files"] = report.FilesCopied
+	fields["directories"] = report.DirsCreated
+	fields["bytes"] = report.BytesCopied
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to copy directory")
+		return report, err
+	}
+	logger.WithFields(fields).Debug("Directory copied successfully")
+	return report, nil
+}
+
+// CopyFile copies the contents of src to dst, preserving the permissions of src
+// dst is overwritten if it already exists
+func CopyFile(src, dst string) error {
+	var err error
+	src, err = BuildAbsolutePathFromHome(src)
+	if err != nil {
+		return err
+	}
+	dst, err = BuildAbsolutePathFromHome(dst)
+	if err != nil {
+		return err
+	}
+	var fields = logrus.Fields{
+		"source":      logPath(src),
+		"destination": logPath(dst),
+	}
+
+	logger.WithFields(fields).Debug("Copying file")
+	defer logDuration(fields, time.Now(), "Copy")
+	if _, err = copyFile(src, dst); err != nil {
+		logger.WithFields(fields).Warn("Failed to copy file")
+		return err
+	}
+	logger.WithFields(fields).Debug("File copied successfully")
+	return nil
+}
+
+// WriteFromReaderThrottled streams the contents of r into path, keeping throughput under rateBytesPerSec
+// path is created or truncated; a rate of 0 writes at full speed
+// A mode of 0 uses the default file mode (see SetDefaultFileMode)
+func WriteFromReaderThrottled(path string, r io.Reader, mode os.FileMode, rateBytesPerSec int64) error {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return err
+	}
+	var fields = logrus.Fields{
+		"file": logPath(path),
+		"rate": rateBytesPerSec,
+	}
+
+	logger.WithFields(fields).Debug("Writing file from reader with throttling")
+	defer logDuration(fields, time.Now(), "Throttled write")
+	if rateBytesPerSec < 0 {
+		err = errors.New("rate must not be negative")
+	} else if r == nil {
+		err = errors.New("no reader provided")
+	} else {
+		if rateBytesPerSec > 0 {
+			r = &throttledReader{reader: r, rate: rateBytesPerSec}
+		}
+		var out writableFile
+		if out, err = osOpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fileMode(mode)); err == nil {
+			_, err = io.Copy(out, r)
+			if closeErr := out.Close(); err == nil {
+				err = closeErr
+			}
+		}
+	}
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to write file")
+		return err
+	}
+	logger.WithFields(fields).Debug("File written successfully")
+	return nil
+}
+
+// copyFile copies src to dst and returns the number of bytes copied
+func copyFile(src, dst string) (int64, error) {
+	return copyFileWith(src, dst, nil)
+}
+
+// copyFileWith copies src to dst, giving dst the permissions of src, and returns the number of bytes copied
+// When wrap is provided, the contents of src are read through the reader it returns
+func copyFileWith(src, dst string, wrap func(io.Reader) io.Reader) (int64, error) {
+	if !isFile(src) {
+		return 0, errors.New(src + " is not a file")
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	stat, err := in.Stat()
+	if err != nil {
+		return 0, err
+	}
+	out, err := osOpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, stat.Mode().Perm())
+	if err != nil {
+		return 0, err
+	}
+	var r io.Reader = in
+	if wrap != nil {
+		r = wrap(in)
+	}
+	n, err := io.Copy(out, r)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		// The mode passed to OpenFile only applies when dst is created
+		err = os.Chmod(dst, stat.Mode().Perm())
+	}
+	return n, err
+}
+
+// resolvePath makes path absolute and resolves symlinks in as much of it as exists
+func resolvePath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	var missing = []string{}
+	for p := path; ; p = filepath.Dir(p) {
+		if resolved, err := filepath.EvalSymlinks(p); err == nil {
+			return filepath.Join(append([]string{resolved}, missing...)...)
+		}
+		if p == filepath.Dir(p) {
+			return path
+		}
+		missing = append([]string{filepath.Base(p)}, missing...)
+	}
+}
+
+// sparseBlockSize is the granularity at which copyFileSparse looks for runs of zeros
+const sparseBlockSize = 4096
+
+// copyFileSparse copies src to dst, seeking past blocks of zeros instead of writing them
+func copyFileSparse(src, dst string) error {
+	if !isFile(src) {
+		return errors.New(src + " is not a file")
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	stat, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, stat.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	var block = make([]byte, sparseBlockSize)
+	var zeros = make([]byte, sparseBlockSize)
+	var offset int64
+	for err == nil {
+		var n int
+		n, err = io.ReadFull(in, block)
+		if n > 0 {
+			if bytes.Equal(block[:n], zeros[:n]) {
+				_, err = out.Seek(int64(n), io.SeekCurrent)
+			} else {
+				_, err = out.Write(block[:n])
+			}
+			offset += int64(n)
+		}
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		// Seeking past trailing zeros doesn't extend the file, so set the final size explicitly
+		err = out.Truncate(offset)
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// throttledReader limits reads from reader to rate bytes per second on average
+// Reads are capped at one second's worth of data, and each read sleeps until the total read so far is within the rate
+type throttledReader struct {
+	reader io.Reader
+	rate   int64
+	start  time.Time
+	total  int64
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if t.start.IsZero() {
+		t.start = time.Now()
+	}
+	if int64(len(p)) > t.rate {
+		p = p[:t.rate]
+	}
+	n, err := t.reader.Read(p)
+	t.total += int64(n)
+	var due = time.Duration(float64(t.total) / float64(t.rate) * float64(time.Second))
+	if wait := due - time.Since(t.start); wait > 0 {
+		time.Sleep(wait)
+	}
+	return n, err
+}
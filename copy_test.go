@@ -0,0 +1,343 @@
+package filesystem
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+func TestCopyFile(t *testing.T) {
+	color.Yellow("Testing file copies")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var src = tempDir + "/source.file"
+	var dst = tempDir + "/destination.file"
+	WriteFile(src, []byte("test"), 0600)
+	if err := CopyFile(src, dst); err != nil {
+		t.Error("CopyFile failed:", src, dst, err)
+	}
+	if c, err := LoadFileString(dst); err != nil || c != "test" {
+		t.Error("Copied file contents don't match:", "Got:", c, "Wanted:", "test")
+	}
+	if stat, err := os.Stat(dst); err != nil {
+		t.Error("Could not stat copied file:", dst, err)
+	} else if stat.Mode().Perm() != 0600 {
+		t.Error("Copied file mode incorrect:", "Got:", stat.Mode().Perm(), "Wanted:", os.FileMode(0600))
+	}
+
+	// An existing destination takes on the permissions of the source too
+	var existing = tempDir + "/existing.file"
+	WriteFile(existing, []byte("old"), 0644)
+	if err := CopyFile(src, existing); err != nil {
+		t.Error("CopyFile failed:", src, existing, err)
+	}
+	if stat, err := os.Stat(existing); err != nil {
+		t.Error("Could not stat copied file:", existing, err)
+	} else if stat.Mode().Perm() != 0600 {
+		t.Error("Copied file mode incorrect:", "Got:", stat.Mode().Perm(), "Wanted:", os.FileMode(0600))
+	}
+
+	if err := CopyFile(tempDir+"/file-dne", dst); err == nil {
+		t.Error("CopyFile succeeded with non-existent source")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestCopyDirectory(t *testing.T) {
+	color.Yellow("Testing directory copies")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var src = tempDir + "/source"
+	var dst = tempDir + "/destination"
+	CreateDirectory(src + "/a/b")
+	CreateDirectory(src + "/c")
+	WriteFile(src+"/root.file", []byte("test"), 0644)
+	WriteFile(src+"/a/nested.file", []byte("nested"), 0644)
+	WriteFile(src+"/a/b/deep.file", []byte("deeply nested"), 0644)
+	os.Symlink(src+"/root.file", src+"/link")
+
+	report, err := CopyDirectory(src, dst)
+	if err != nil {
+		t.Fatal("CopyDirectory failed:", src, dst, err)
+	}
+	if report.FilesCopied != 3 {
+		t.Error("Incorrect files copied count:", "Got:", report.FilesCopied, "Wanted:", 3)
+	}
+	if report.DirsCreated != 4 {
+		t.Error("Incorrect directories created count:", "Got:", report.DirsCreated, "Wanted:", 4)
+	}
+	if report.BytesCopied != 23 {
+		t.Error("Incorrect bytes copied count:", "Got:", report.BytesCopied, "Wanted:", 23)
+	}
+	if !reflect.DeepEqual(report.Skipped, []string{"link"}) {
+		t.Error("Incorrect skipped entries:", "Got:", report.Skipped)
+	}
+	if len(report.Failed) != 0 {
+		t.Error("Unexpected failed entries:", report.Failed)
+	}
+	if c, err := LoadFileString(dst + "/a/b/deep.file"); err != nil || c != "deeply nested" {
+		t.Error("Copied file contents don't match:", "Got:", c, "Wanted:", "deeply nested")
+	}
+	if !IsEmptyDirectory(dst + "/c") {
+		t.Error("Empty directory was not copied:", dst+"/c")
+	}
+
+	if _, err := CopyDirectory(tempDir+"/dne", dst); err == nil {
+		t.Error("CopyDirectory succeeded with non-existent source")
+	}
+
+	// Copying a directory into itself is refused rather than recursing forever
+	for _, into := range []string{src, src + "/copy", src + "/a/../copy"} {
+		if _, err := CopyDirectory(src, into); err == nil {
+			t.Error("CopyDirectory succeeded copying a directory into itself:", into)
+		}
+	}
+	os.Symlink(src, tempDir+"/link")
+	if _, err := CopyDirectory(src, tempDir+"/link/copy"); err == nil {
+		t.Error("CopyDirectory succeeded copying a directory into itself through a symlink")
+	}
+	if CheckExists(src + "/copy") {
+		t.Error("Refused copy created the destination")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestCopyDirectoryPreserveTimes(t *testing.T) {
+	color.Yellow("Testing directory copies with preserved times")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var src = tempDir + "/source"
+	var dst = tempDir + "/destination"
+	CreateDirectory(src + "/a/b")
+	WriteFile(src+"/a/b/deep.file", []byte("test"), 0644)
+	WriteFile(src+"/a/nested.file", []byte("test"), 0644)
+	var past = time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	for i, dir := range []string{src, src + "/a", src + "/a/b"} {
+		var modTime = past.Add(time.Duration(i) * time.Hour)
+		os.Chtimes(dir, modTime, modTime)
+	}
+
+	if _, err := CopyDirectoryPreserveTimes(src, dst); err != nil {
+		t.Fatal("CopyDirectoryPreserveTimes failed:", src, dst, err)
+	}
+	for _, relPath := range []string{"", "/a", "/a/b"} {
+		srcStat, err := os.Stat(src + relPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dstStat, err := os.Stat(dst + relPath)
+		if err != nil {
+			t.Error("Copied directory is missing:", dst+relPath)
+		} else if !dstStat.ModTime().Equal(srcStat.ModTime()) {
+			t.Error("Directory modification time not preserved:", dst+relPath, "Got:", dstStat.ModTime(), "Wanted:", srcStat.ModTime())
+		}
+	}
+
+	// Without preservation, the copy gets a fresh modification time
+	if _, err := CopyDirectory(src, tempDir+"/unpreserved"); err != nil {
+		t.Fatal("CopyDirectory failed:", src, err)
+	}
+	if stat, err := os.Stat(tempDir + "/unpreserved/a"); err != nil || stat.ModTime().Equal(past.Add(time.Hour)) {
+		t.Error("CopyDirectory should not preserve directory modification times")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+// corruptingWriter simulates a destination that silently flips bits
+type corruptingWriter struct {
+	writableFile
+}
+
+func (w corruptingWriter) Write(p []byte) (int, error) {
+	var corrupted = append([]byte{}, p...)
+	if len(corrupted) > 0 {
+		corrupted[0] ^= 0xff
+	}
+	return w.writableFile.Write(corrupted)
+}
+
+func TestCopyFileVerified(t *testing.T) {
+	color.Yellow("Testing verified file copies")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var src = tempDir + "/source.file"
+	var dst = tempDir + "/destination.file"
+	WriteFile(src, []byte("test"), 0644)
+	if err := CopyFileVerified(src, dst); err != nil {
+		t.Error("CopyFileVerified failed:", src, dst, err)
+	}
+	if c, err := LoadFileString(dst); err != nil || c != "test" {
+		t.Error("Copied file contents don't match:", "Got:", c, "Wanted:", "test")
+	}
+	DeleteFile(dst)
+
+	var openFile = osOpenFile
+	defer func() {
+		osOpenFile = openFile
+	}()
+	osOpenFile = func(name string, flag int, perm os.FileMode) (writableFile, error) {
+		f, err := openFile(name, flag, perm)
+		if err != nil {
+			return nil, err
+		}
+		return corruptingWriter{f}, nil
+	}
+
+	err = CopyFileVerified(src, dst)
+	if mismatch, ok := err.(*ErrChecksumMismatch); !ok {
+		t.Error("CopyFileVerified should return *ErrChecksumMismatch for a corrupted copy:", "Got:", err)
+	} else if mismatch.Expected != "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08" {
+		t.Error("Checksum mismatch error has incorrect expected digest:", mismatch.Expected)
+	}
+	if CheckExists(dst) {
+		t.Error("Corrupted copy was left behind:", dst)
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestCopyFileThrottled(t *testing.T) {
+	color.Yellow("Testing throttled file copies")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var src = tempDir + "/source.file"
+	var dst = tempDir + "/destination.file"
+	var data = bytes.Repeat([]byte("test"), 8*1024)
+	WriteFile(src, data, 0644)
+
+	// 32KiB at 64KiB/s should take at least half a second
+	var start = time.Now()
+	if err := CopyFileThrottled(src, dst, 64*1024); err != nil {
+		t.Error("CopyFileThrottled failed:", src, dst, err)
+	}
+	if elapsed := time.Since(start); elapsed < 450*time.Millisecond {
+		t.Error("CopyFileThrottled copied faster than the rate allows:", elapsed)
+	}
+	if c, err := LoadFileBytes(dst); err != nil || !bytes.Equal(c, data) {
+		t.Error("Copied file contents don't match the source:", err)
+	}
+
+	DeleteFile(dst)
+	if err := CopyFileThrottled(src, dst, 0); err != nil || !CheckExists(dst) {
+		t.Error("CopyFileThrottled failed without a rate limit:", err)
+	}
+	if err := CopyFileThrottled(src, dst, -1); err == nil {
+		t.Error("CopyFileThrottled succeeded with a negative rate")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestWriteFromReaderThrottled(t *testing.T) {
+	color.Yellow("Testing throttled writes from a reader")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var dst = tempDir + "/destination.file"
+	var data = bytes.Repeat([]byte("test"), 8*1024)
+
+	// 32KiB at 64KiB/s should take at least half a second
+	var start = time.Now()
+	if err := WriteFromReaderThrottled(dst, bytes.NewReader(data), 0600, 64*1024); err != nil {
+		t.Error("WriteFromReaderThrottled failed:", dst, err)
+	}
+	if elapsed := time.Since(start); elapsed < 450*time.Millisecond {
+		t.Error("WriteFromReaderThrottled wrote faster than the rate allows:", elapsed)
+	}
+	if c, err := LoadFileBytes(dst); err != nil || !bytes.Equal(c, data) {
+		t.Error("Written file contents don't match the reader:", err)
+	}
+	if info, err := os.Stat(dst); err != nil || info.Mode().Perm() != 0600 {
+		t.Error("WriteFromReaderThrottled set the wrong mode:", "Got:", info, "Wanted:", os.FileMode(0600), err)
+	}
+
+	if err := WriteFromReaderThrottled(dst, strings.NewReader("abc"), 0, 0); err != nil {
+		t.Error("WriteFromReaderThrottled failed without a rate limit:", err)
+	}
+	if c, _ := LoadFileString(dst); c != "abc" {
+		t.Error("WriteFromReaderThrottled did not truncate the file:", "Got:", c, "Wanted:", "abc")
+	}
+	if err := WriteFromReaderThrottled(dst, strings.NewReader("abc"), 0, -1); err == nil {
+		t.Error("WriteFromReaderThrottled succeeded with a negative rate")
+	}
+	if err := WriteFromReaderThrottled(dst, nil, 0, 0); err == nil {
+		t.Error("WriteFromReaderThrottled succeeded without a reader")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestCopyTruncate(t *testing.T) {
+	color.Yellow("Testing copy-truncate rotation")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var logFile = tempDir + "/app.log"
+	var rotated = tempDir + "/app.log.1"
+	writer, err := os.OpenFile(logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	writer.WriteString("first\nsecond\n")
+	before, _ := os.Stat(logFile)
+
+	if err = CopyTruncate(logFile, rotated); err != nil {
+		t.Error("CopyTruncate failed:", err)
+	}
+	if c, err := LoadFileString(rotated); err != nil || c != "first\nsecond\n" {
+		t.Error("Rotated copy has unexpected contents:", "Got:", c, err, "Wanted:", "first\nsecond\n")
+	}
+	if c, err := LoadFileString(logFile); err != nil || c != "" {
+		t.Error("Original was not truncated:", "Got:", c, err, "Wanted:", "")
+	}
+	if after, err := os.Stat(logFile); err != nil || !os.SameFile(before, after) {
+		t.Error("CopyTruncate replaced the original file")
+	}
+
+	// The open writer keeps working
+	writer.WriteString("third\n")
+	if c, _ := LoadFileString(logFile); c != "third\n" {
+		t.Error("Writes after truncation have unexpected contents:", "Got:", c, "Wanted:", "third\n")
+	}
+	if err = CopyTruncate(tempDir+"/missing.log", rotated); err == nil {
+		t.Error("CopyTruncate succeeded with a missing source")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
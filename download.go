@@ -0,0 +1,89 @@
+package filesystem
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// partSuffix is appended to a download's final path while it is in progress
+const partSuffix = ".part"
+
+// FinalizeDownload renames the in-progress download path.part to path once it is complete (see IsCompleteDownload)
+// If the download isn't complete, it is left in place and an error is returned
+func FinalizeDownload(path string, expectedSize int64, expectedSHA256 string) error {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return err
+	}
+	var partPath = path + partSuffix
+	var fields = logrus.Fields{
+		"file": logPath(path),
+	}
+
+	logger.WithFields(fields).Debug("Finalizing download")
+	complete, err := IsCompleteDownload(partPath, expectedSize, expectedSHA256)
+	if err == nil && !complete {
+		err = errors.New(partPath + " is not a complete download")
+	}
+	if err == nil {
+		err = os.Rename(partPath, path)
+	}
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to finalize download")
+		return err
+	}
+	logger.WithFields(fields).Debug("Download finalized")
+	return nil
+}
+
+// IsCompleteDownload reports whether the file at path has expectedSize bytes and, when expectedSHA256 isn't
+// empty, a matching SHA-256 checksum
+// The checksum is only computed once the size matches; a missing file is reported as incomplete
+func IsCompleteDownload(path string, expectedSize int64, expectedSHA256 string) (bool, error) {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return false, err
+	}
+	var fields = logrus.Fields{
+		"file":         logPath(path),
+		"expectedSize": expectedSize,
+	}
+
+	logger.WithFields(fields).Debug("Checking download")
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err == nil && info.IsDir() {
+		err = errors.New(path + " is not a file")
+	}
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to check download")
+		return false, err
+	}
+	if info.Size() != expectedSize {
+		fields["size"] = info.Size()
+		logger.WithFields(fields).Debug("Download size doesn't match")
+		return false, nil
+	}
+	if expectedSHA256 == "" {
+		return true, nil
+	}
+
+	checksum, err := fileSHA256Checksum(path)
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to check download")
+		return false, err
+	}
+	if !strings.EqualFold(checksum, expectedSHA256) {
+		fields["checksum"] = checksum
+		logger.WithFields(fields).Debug("Download checksum doesn't match")
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,76 @@
+package filesystem
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestIsCompleteDownload(t *testing.T) {
+	color.Yellow("Testing download completeness checks")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var download = tempDir + "/file.iso.part"
+	var testSum = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	var wrongSum = "0000000000000000000000000000000000000000000000000000000000000000"
+	WriteFile(download, []byte("test"), 0644)
+
+	var testData = []struct {
+		size     int64
+		checksum string
+		expected bool
+	}{
+		{4, testSum, true},
+		{4, strings.ToUpper(testSum), true},
+		{4, "", true},
+		{3, testSum, false},
+		{5, "", false},
+		{4, wrongSum, false},
+	}
+	for _, test := range testData {
+		if complete, err := IsCompleteDownload(download, test.size, test.checksum); err != nil || complete != test.expected {
+			t.Error("IsCompleteDownload failed:", test.size, test.checksum, "Got:", complete, "Wanted:", test.expected, err)
+		}
+	}
+	if complete, err := IsCompleteDownload(tempDir+"/file-dne", 4, ""); err != nil || complete {
+		t.Error("A missing download should be incomplete:", complete, err)
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestFinalizeDownload(t *testing.T) {
+	color.Yellow("Testing download finalization")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var path = tempDir + "/file.iso"
+	var testSum = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	WriteFile(path+".part", []byte("tes"), 0644)
+
+	if err := FinalizeDownload(path, 4, testSum); err == nil {
+		t.Error("FinalizeDownload succeeded with an incomplete download")
+	}
+	if CheckExists(path) || !CheckExists(path+".part") {
+		t.Error("An incomplete download was moved into place")
+	}
+
+	WriteFile(path+".part", []byte("test"), 0644)
+	if err := FinalizeDownload(path, 4, testSum); err != nil {
+		t.Error("FinalizeDownload failed:", path, err)
+	}
+	if c, err := LoadFileString(path); err != nil || c != "test" || CheckExists(path+".part") {
+		t.Error("Download was not moved into place:", "Got:", c, "Wanted:", "test", err)
+	}
+	color.Yellow("Test Complete")
+	println()
+}
@@ -0,0 +1,164 @@
+package filesystem
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Encoding names returned by DetectEncoding
+const (
+	EncodingUTF8    = "utf-8"
+	EncodingUTF16LE = "utf-16le"
+	EncodingUTF16BE = "utf-16be"
+	EncodingLatin1  = "iso-8859-1"
+)
+
+// encodingSampleSize is how much of a file DetectEncoding inspects
+const encodingSampleSize = 8000
+
+var (
+	bomUTF8    = []byte{0xef, 0xbb, 0xbf}
+	bomUTF16LE = []byte{0xff, 0xfe}
+	bomUTF16BE = []byte{0xfe, 0xff}
+)
+
+// DetectEncoding makes a best-effort guess at the text encoding of path from its first few kilobytes
+// A byte order mark identifies UTF-8 and UTF-16; otherwise content that is valid UTF-8 is reported as UTF-8 and
+// anything else as ISO-8859-1 (Latin-1), which can represent any sequence of bytes
+// Returns one of the Encoding constants
+func DetectEncoding(path string) (string, error) {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return "", err
+	}
+	var fields = logrus.Fields{
+		"path": logPath(path),
+	}
+
+	logger.WithFields(fields).Debug("Detecting file encoding")
+	if !isFile(path) {
+		err = errors.New(path + " is not a file")
+		logger.WithFields(fields).Warn("Failed to detect file encoding")
+		return "", err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to detect file encoding")
+		return "", err
+	}
+	defer f.Close()
+
+	var sample = make([]byte, encodingSampleSize)
+	n, err := io.ReadFull(f, sample)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		logger.WithFields(fields).Warn("Failed to detect file encoding")
+		return "", err
+	}
+	var encoding = detectSampleEncoding(sample[:n])
+	fields["encoding"] = encoding
+	logger.WithFields(fields).Debug("Detected file encoding")
+	return encoding, nil
+}
+
+// detectSampleEncoding guesses the encoding of data from its first encodingSampleSize bytes
+// DetectEncoding and ReadFileWithEncoding both use it so the same file is always classified the same way
+func detectSampleEncoding(data []byte) string {
+	if len(data) >= encodingSampleSize {
+		return detectEncoding(data[:encodingSampleSize], true)
+	}
+	return detectEncoding(data, false)
+}
+
+// detectEncoding guesses the encoding of data; when truncated is set, a partial character at the end is ignored
+func detectEncoding(data []byte, truncated bool) string {
+	switch {
+	case bytes.HasPrefix(data, bomUTF8):
+		return EncodingUTF8
+	case bytes.HasPrefix(data, bomUTF16LE):
+		return EncodingUTF16LE
+	case bytes.HasPrefix(data, bomUTF16BE):
+		return EncodingUTF16BE
+	}
+	if truncated {
+		// The sample may end part way through a multi-byte character
+		for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+			if utf8.RuneStart(data[i]) {
+				if !utf8.FullRune(data[i:]) {
+					data = data[:i]
+				}
+				break
+			}
+		}
+	}
+	if utf8.Valid(data) {
+		return EncodingUTF8
+	}
+	return EncodingLatin1
+}
+
+// ReadFileWithEncoding loads the contents of path as UTF-8 text, decoding it from the encoding found by DetectEncoding
+// Like DetectEncoding, only the first few kilobytes decide the encoding; if later bytes aren't valid UTF-8 in a
+// file detected as UTF-8, they are returned as-is
+// Any byte order mark is removed; returns the text along with the detected encoding
+func ReadFileWithEncoding(path string) (string, string, error) {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return "", "", err
+	}
+	var fields = logrus.Fields{
+		"path": logPath(path),
+	}
+
+	logger.WithFields(fields).Debug("Reading file with encoding detection")
+	if !isFile(path) {
+		err = errors.New(path + " is not a file")
+		logger.WithFields(fields).Warn("Failed to read file")
+		return "", "", err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to read file")
+		return "", "", err
+	}
+
+	var encoding = detectSampleEncoding(data)
+	fields["encoding"] = encoding
+	var text string
+	switch encoding {
+	case EncodingUTF16LE, EncodingUTF16BE:
+		text = decodeUTF16(data[len(bomUTF16LE):], encoding == EncodingUTF16BE)
+	case EncodingLatin1:
+		var b strings.Builder
+		for _, c := range data {
+			b.WriteRune(rune(c))
+		}
+		text = b.String()
+	default:
+		text = string(bytes.TrimPrefix(data, bomUTF8))
+	}
+	logger.WithFields(fields).Debug("File read successfully")
+	return text, encoding, nil
+}
+
+// decodeUTF16 decodes UTF-16 data without a byte order mark; a trailing odd byte is dropped
+func decodeUTF16(data []byte, bigEndian bool) string {
+	var units = make([]uint16, len(data)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+		} else {
+			units[i] = uint16(data[2*i+1])<<8 | uint16(data[2*i])
+		}
+	}
+	return string(utf16.Decode(units))
+}
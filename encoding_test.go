@@ -0,0 +1,55 @@
+package filesystem
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestDetectEncoding(t *testing.T) {
+	color.Yellow("Testing text encoding detection")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var testData = []struct {
+		name     string
+		contents []byte
+		encoding string
+		text     string
+	}{
+		{"utf8.txt", []byte("caf\xc3\xa9"), EncodingUTF8, "café"},
+		{"utf8-bom.txt", []byte("\xef\xbb\xbfcaf\xc3\xa9"), EncodingUTF8, "café"},
+		{"utf16le.txt", []byte{0xff, 0xfe, 'c', 0, 'a', 0, 'f', 0, 0xe9, 0}, EncodingUTF16LE, "café"},
+		{"utf16be.txt", []byte{0xfe, 0xff, 0, 'c', 0, 'a', 0, 'f', 0, 0xe9}, EncodingUTF16BE, "café"},
+		{"latin1.txt", []byte("caf\xe9"), EncodingLatin1, "café"},
+		{"empty.txt", []byte{}, EncodingUTF8, ""},
+		// A multi-byte character split by the end of the sample shouldn't be mistaken for Latin-1
+		{"long.txt", append(bytes.Repeat([]byte{'a'}, encodingSampleSize-1), "\xc3\xa9"...), EncodingUTF8, string(bytes.Repeat([]byte{'a'}, encodingSampleSize-1)) + "é"},
+		// Only the sample decides the encoding, for both DetectEncoding and ReadFileWithEncoding
+		{"late-latin1.txt", append(bytes.Repeat([]byte{'a'}, encodingSampleSize), 0xe9), EncodingUTF8, string(bytes.Repeat([]byte{'a'}, encodingSampleSize)) + "\xe9"},
+	}
+
+	for _, test := range testData {
+		var path = tempDir + "/" + test.name
+		WriteFile(path, test.contents, 0644)
+		if encoding, err := DetectEncoding(path); err != nil || encoding != test.encoding {
+			t.Error("DetectEncoding failed:", test.name, "Got:", encoding, "Wanted:", test.encoding, err)
+		}
+		if text, encoding, err := ReadFileWithEncoding(path); err != nil || text != test.text || encoding != test.encoding {
+			t.Error("ReadFileWithEncoding failed:", test.name, "Got:", text, encoding, "Wanted:", test.text, test.encoding, err)
+		}
+	}
+	if _, err := DetectEncoding(tempDir + "/file-dne"); err == nil {
+		t.Error("DetectEncoding succeeded with non-existent file")
+	}
+	if _, _, err := ReadFileWithEncoding(tempDir); err == nil {
+		t.Error("ReadFileWithEncoding succeeded with a directory")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
@@ -0,0 +1,124 @@
+package filesystem
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// envKey matches the keys accepted in env files
+var envKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// LoadEnvFile reads the KEY=VALUE pairs in the env file at path
+// Blank lines and lines starting with # are ignored, an optional "export " prefix is allowed, and whitespace
+// around keys and values is trimmed; values wrapped in single quotes are used as-is, while values wrapped in
+// double quotes also have escape sequences (\n, \", etc.) interpreted
+// Any other line is malformed and returns an error naming the line
+func LoadEnvFile(path string) (map[string]string, error) {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return nil, err
+	}
+	var fields = logrus.Fields{
+		"file": logPath(path),
+	}
+
+	logger.WithFields(fields).Debug("Loading env file")
+	contents, err := LoadFileBytes(path)
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to load env file")
+		return nil, err
+	}
+
+	var env = map[string]string{}
+	var scanner = bufio.NewScanner(bytes.NewReader(contents))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		var line = strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, err := parseEnvLine(strings.TrimPrefix(line, "export "))
+		if err != nil {
+			err = errors.New(path + ":" + strconv.Itoa(lineNumber) + ": " + err.Error())
+			logger.WithFields(fields).Warn("Failed to load env file")
+			return nil, err
+		}
+		env[key] = value
+	}
+	if err = scanner.Err(); err != nil {
+		logger.WithFields(fields).Warn("Failed to load env file")
+		return nil, err
+	}
+	fields["keys"] = len(env)
+	logger.WithFields(fields).Debug("Env file loaded successfully")
+	return env, nil
+}
+
+// parseEnvLine splits a KEY=VALUE line, unquoting the value if needed
+func parseEnvLine(line string) (string, string, error) {
+	var i = strings.Index(line, "=")
+	if i < 0 {
+		return "", "", errors.New("missing = in env line")
+	}
+	var key = strings.TrimSpace(line[:i])
+	var value = strings.TrimSpace(line[i+1:])
+	if !envKey.MatchString(key) {
+		return "", "", errors.New("invalid env key: " + strconv.Quote(key))
+	}
+	if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+		return key, value[1 : len(value)-1], nil
+	}
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return "", "", errors.New("invalid quoted env value for " + key)
+		}
+		return key, unquoted, nil
+	}
+	return key, value, nil
+}
+
+// WriteEnvFile writes env to path as KEY=VALUE lines sorted by key, in a form LoadEnvFile reads back unchanged
+// Values that are empty or contain whitespace, quotes, #, or non-printable characters are double-quoted
+// The file is written atomically (see WriteFileAtomic); a mode of 0 uses the default file mode (see SetDefaultFileMode)
+func WriteEnvFile(path string, env map[string]string, mode os.FileMode) error {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return err
+	}
+	var fields = logrus.Fields{
+		"file": logPath(path),
+		"keys": len(env),
+	}
+
+	logger.WithFields(fields).Debug("Writing env file")
+	var keys = make([]string, 0, len(env))
+	for key := range env {
+		if !envKey.MatchString(key) {
+			err = errors.New("invalid env key: " + strconv.Quote(key))
+			logger.WithFields(fields).Warn("Failed to write env file")
+			return err
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	for _, key := range keys {
+		var value = env[key]
+		if value == "" || strings.ContainsAny(value, " \t\r\n\"'#\\") || strconv.Quote(value) != `"`+value+`"` {
+			value = strconv.Quote(value)
+		}
+		buf.WriteString(key + "=" + value + "\n")
+	}
+	return WriteFileAtomic(path, buf.Bytes(), mode)
+}
@@ -0,0 +1,90 @@
+package filesystem
+
+import (
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestLoadEnvFile(t *testing.T) {
+	color.Yellow("Testing env file parsing")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var envFile = tempDir + "/.env"
+	WriteFile(envFile, []byte(`# Database settings
+DB_HOST = localhost
+
+export DB_PORT=5432
+DB_NAME="app db"
+DB_PASSWORD='p@ss"word'
+DB_NOTES="line one\nline two"
+EMPTY=
+  # indented comment
+`), 0644)
+
+	var expected = map[string]string{
+		"DB_HOST":     "localhost",
+		"DB_PORT":     "5432",
+		"DB_NAME":     "app db",
+		"DB_PASSWORD": `p@ss"word`,
+		"DB_NOTES":    "line one\nline two",
+		"EMPTY":       "",
+	}
+	if env, err := LoadEnvFile(envFile); err != nil || !reflect.DeepEqual(env, expected) {
+		t.Error("LoadEnvFile failed:", "Got:", env, "Wanted:", expected, err)
+	}
+
+	for _, line := range []string{"NO_EQUALS", "BAD KEY=value", "=value", `QUOTE="unterminated\"`} {
+		WriteFile(envFile, []byte("GOOD=value\n"+line+"\n"), 0644)
+		if _, err := LoadEnvFile(envFile); err == nil {
+			t.Error("LoadEnvFile succeeded with a malformed line:", line)
+		}
+	}
+	if _, err := LoadEnvFile(tempDir + "/file-dne"); err == nil {
+		t.Error("LoadEnvFile succeeded with non-existent file")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestWriteEnvFile(t *testing.T) {
+	color.Yellow("Testing env file round trips")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var envFile = tempDir + "/.env"
+	var env = map[string]string{
+		"PLAIN":    "value",
+		"SPACES":   "  padded value  ",
+		"QUOTES":   `it's "quoted"`,
+		"COMMENT":  "not # a comment",
+		"NEWLINE":  "one\ntwo",
+		"EMPTY":    "",
+		"UNICODE":  "café",
+		"BACKSLSH": `C:\path`,
+	}
+	if err := WriteEnvFile(envFile, env, 0600); err != nil {
+		t.Error("WriteEnvFile failed:", envFile, err)
+	}
+	if loaded, err := LoadEnvFile(envFile); err != nil || !reflect.DeepEqual(loaded, env) {
+		t.Error("Env file did not round trip:", "Got:", loaded, "Wanted:", env, err)
+	}
+	if c, _ := LoadFileString(envFile); !strings.HasPrefix(c, "BACKSLSH=") {
+		t.Error("Env file is not sorted by key:", c)
+	}
+	if err := WriteEnvFile(envFile, map[string]string{"BAD KEY": "value"}, 0600); err == nil {
+		t.Error("WriteEnvFile succeeded with an invalid key")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
@@ -0,0 +1,126 @@
+package filesystem
+
+import (
+	"errors"
+	"os"
+	"strconv"
+)
+
+// ErrTimeout is returned when an operation doesn't complete before its deadline
+var ErrTimeout = errors.New("operation timed out")
+
+// ErrVerificationFailed is returned when a file doesn't read back with the contents just written to it
+var ErrVerificationFailed = errors.New("file contents did not match after writing")
+
+// ErrChecksumMismatch is returned when a file's contents don't match the expected SHA-256 checksum
+type ErrChecksumMismatch struct {
+	Path     string
+	Expected string
+	Actual   string
+}
+
+func (e *ErrChecksumMismatch) Error() string {
+	return "checksum mismatch for " + e.Path + ": expected " + e.Expected + ", got " + e.Actual
+}
+
+// ErrSymlinkDestination is returned when a write refuses to follow a symlink at the destination path
+type ErrSymlinkDestination struct {
+	Path string
+}
+
+func (e *ErrSymlinkDestination) Error() string {
+	return e.Path + " is a symlink; refusing to write through it"
+}
+
+// ErrDirectoryNotEmpty is returned when a directory that is required to be empty has contents
+type ErrDirectoryNotEmpty struct {
+	Path string
+}
+
+func (e *ErrDirectoryNotEmpty) Error() string {
+	return e.Path + " is not empty"
+}
+
+// ErrLineRange is returned when a requested range of lines is invalid or extends past the end of a file
+type ErrLineRange struct {
+	Path  string
+	Start int
+	End   int
+}
+
+func (e *ErrLineRange) Error() string {
+	return "invalid line range " + strconv.Itoa(e.Start) + "-" + strconv.Itoa(e.End) + " for " + e.Path
+}
+
+// ErrEmptyDirectory is returned when a directory has no files to operate on
+type ErrEmptyDirectory struct {
+	Path string
+}
+
+func (e *ErrEmptyDirectory) Error() string {
+	return e.Path + " contains no files"
+}
+
+// ErrNotDescendant is returned when a path is expected to be inside a root directory but isn't
+type ErrNotDescendant struct {
+	Root string
+	Path string
+}
+
+func (e *ErrNotDescendant) Error() string {
+	return e.Path + " is not under " + e.Root
+}
+
+// ErrSymlinkLoop is returned when following a chain of symlinks leads back to a link already visited
+type ErrSymlinkLoop struct {
+	Path string
+}
+
+func (e *ErrSymlinkLoop) Error() string {
+	return "symlink loop detected at " + e.Path
+}
+
+// ErrUnexpectedOwner is returned when a path is owned by a different user than required
+type ErrUnexpectedOwner struct {
+	Path     string
+	Expected int
+	Actual   int
+}
+
+func (e *ErrUnexpectedOwner) Error() string {
+	return e.Path + " is owned by uid " + strconv.Itoa(e.Actual) + ", expected uid " + strconv.Itoa(e.Expected)
+}
+
+// ErrParseValue is returned when a file's contents can't be parsed as the requested type
+type ErrParseValue struct {
+	Path string
+	Err  error
+}
+
+func (e *ErrParseValue) Error() string {
+	return "could not parse " + e.Path + ": " + e.Err.Error()
+}
+
+func (e *ErrParseValue) Unwrap() error {
+	return e.Err
+}
+
+// ErrPathEscape is returned when a path resolves to a location outside the root it must stay within
+type ErrPathEscape struct {
+	Root string
+	Path string
+}
+
+func (e *ErrPathEscape) Error() string {
+	return e.Path + " escapes " + e.Root
+}
+
+// ErrInsecureDirectory is returned when a directory that must be trusted can be modified by other users
+type ErrInsecureDirectory struct {
+	Path string
+	Mode os.FileMode
+}
+
+func (e *ErrInsecureDirectory) Error() string {
+	return e.Path + " is writable by other users (" + e.Mode.String() + ")"
+}
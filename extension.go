@@ -0,0 +1,124 @@
+package filesystem
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// sniffedExtensions maps content types detected by http.DetectContentType to their accepted extensions
+// The first extension listed is the canonical one
+var sniffedExtensions = map[string][]string{
+	"application/ogg":               {"ogg", "oga", "ogv"},
+	"application/pdf":               {"pdf"},
+	"application/postscript":        {"ps", "eps"},
+	"application/vnd.ms-fontobject": {"eot"},
+	"application/wasm":              {"wasm"},
+	"application/x-gzip":            {"gz", "tgz"},
+	"application/x-rar-compressed":  {"rar"},
+	"application/zip":               {"zip", "jar", "docx", "xlsx", "pptx", "odt", "epub", "apk"},
+	"audio/aiff":                    {"aiff", "aif"},
+	"audio/basic":                   {"au", "snd"},
+	"audio/midi":                    {"mid", "midi"},
+	"audio/mpeg":                    {"mp3"},
+	"audio/wave":                    {"wav"},
+	"font/otf":                      {"otf"},
+	"font/collection":               {"ttc"},
+	"font/ttf":                      {"ttf"},
+	"font/woff":                     {"woff"},
+	"font/woff2":                    {"woff2"},
+	"image/bmp":                     {"bmp"},
+	"image/gif":                     {"gif"},
+	"image/jpeg":                    {"jpg", "jpeg"},
+	"image/png":                     {"png"},
+	"image/webp":                    {"webp"},
+	"image/x-icon":                  {"ico"},
+	"text/html":                     {"html", "htm"},
+	"text/xml":                      {"xml", "svg", "rss", "atom"},
+	"video/avi":                     {"avi"},
+	"video/mp4":                     {"mp4", "m4v", "m4a"},
+	"video/webm":                    {"webm", "weba"},
+}
+
+// FixExtension renames path so its extension matches its detected content type
+// Returns the (possibly unchanged) path of the file; an existing file at the new path is never overwritten, and an
+// error satisfying os.IsExist is returned instead
+func FixExtension(path string) (string, error) {
+	matches, detected, err := VerifyExtension(path)
+	if err != nil || matches {
+		return path, err
+	}
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return path, err
+	}
+	var newPath = strings.TrimSuffix(path, "."+GetFileExtension(path)) + "." + detected
+	var fields = logrus.Fields{
+		"file":    logPath(path),
+		"renamed": logPath(newPath),
+	}
+
+	logger.WithFields(fields).Debug("Fixing file extension")
+	if err = moveFileNoReplace(path, newPath); err != nil {
+		logger.WithFields(fields).Warn("Failed to fix file extension")
+		return path, err
+	}
+	logger.WithFields(fields).Debug("File extension fixed")
+	return newPath, nil
+}
+
+// VerifyExtension checks whether the extension of path matches its content
+// Returns whether the extension matches along with the canonical extension for the detected content type
+// Content that can't be identified beyond plain text or binary data is always reported as matching, with an empty detected extension
+func VerifyExtension(path string) (bool, string, error) {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return false, "", err
+	}
+	var fields = logrus.Fields{
+		"file": logPath(path),
+	}
+
+	logger.WithFields(fields).Debug("Verifying file extension")
+	if !isFile(path) {
+		err = errors.New(path + " is not a file")
+		logger.WithFields(fields).Warn("Failed to verify file extension")
+		return false, "", err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to verify file extension")
+		return false, "", err
+	}
+	defer f.Close()
+
+	// DetectContentType considers at most the first 512 bytes
+	var header = make([]byte, 512)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		logger.WithFields(fields).Warn("Failed to verify file extension")
+		return false, "", err
+	}
+	var contentType = strings.SplitN(http.DetectContentType(header[:n]), ";", 2)[0]
+	fields["type"] = contentType
+
+	extensions, ok := sniffedExtensions[contentType]
+	if !ok {
+		logger.WithFields(fields).Debug("Content type is not specific enough to verify extension")
+		return true, "", nil
+	}
+	var ext = strings.ToLower(GetFileExtension(path))
+	for _, e := range extensions {
+		if ext == e {
+			logger.WithFields(fields).Debug("File extension matches content")
+			return true, extensions[0], nil
+		}
+	}
+	logger.WithFields(fields).Info("File extension does not match content")
+	return false, extensions[0], nil
+}
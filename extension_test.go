@@ -0,0 +1,59 @@
+package filesystem
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestVerifyExtension(t *testing.T) {
+	color.Yellow("Testing file extension verification")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var png = []byte("\x89PNG\x0D\x0A\x1A\x0A\x00\x00\x00\x0DIHDR")
+	var correct = tempDir + "/image.png"
+	var misnamed = tempDir + "/image.txt"
+	var text = tempDir + "/notes.md"
+	WriteFile(correct, png, 0644)
+	WriteFile(misnamed, png, 0644)
+	WriteFile(text, []byte("# Notes"), 0644)
+
+	if matches, detected, err := VerifyExtension(correct); err != nil || !matches || detected != "png" {
+		t.Error("Correctly named file failed verification:", correct, matches, detected, err)
+	}
+	if matches, detected, err := VerifyExtension(misnamed); err != nil || matches || detected != "png" {
+		t.Error("Misnamed file passed verification:", misnamed, matches, detected, err)
+	}
+	if matches, _, err := VerifyExtension(text); err != nil || !matches {
+		t.Error("Plain text file failed verification:", text, matches, err)
+	}
+
+	if p, err := FixExtension(correct); err != nil || p != correct {
+		t.Error("FixExtension should leave correctly named files alone:", "Got:", p, err)
+	}
+	if p, err := FixExtension(misnamed); !os.IsExist(err) {
+		t.Error("FixExtension should refuse to overwrite an existing file:", p, err)
+	}
+	if !IsFile(misnamed) || !IsFile(correct) {
+		t.Error("A refused rename changed the files")
+	}
+	DeleteFile(correct)
+	if p, err := FixExtension(misnamed); err != nil || p != correct {
+		t.Error("FixExtension failed:", "Got:", p, "Wanted:", correct, err)
+	}
+	if IsFile(misnamed) || !IsFile(correct) {
+		t.Error("FixExtension did not rename the file:", misnamed)
+	}
+
+	if _, _, err := VerifyExtension(tempDir + "/file-dne"); err == nil {
+		t.Error("VerifyExtension succeeded with non-existent file")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
@@ -1,13 +1,20 @@
 package filesystem
 
 import (
+	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"io"
 	"io/ioutil"
 	"os"
+	"os/user"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/mitchellh/go-homedir"
 	"github.com/sirupsen/logrus"
@@ -15,23 +22,151 @@ import (
 
 var logger = logrus.New()
 var verbosity = uint8(0)
+var redactPaths = false
+var logTimings = false
+var defaultFileMode = os.FileMode(0)
+var defaultDirMode = os.FileMode(0)
+var fallbackTempDir = ""
+
+// FileStat is a point-in-time record of a filesystem entry's metadata
+type FileStat struct {
+	Name    string
+	Size    int64
+	Mode    os.FileMode
+	ModTime time.Time
+	IsDir   bool
+}
+
+// newFileStat builds a FileStat from the provided os.FileInfo
+func newFileStat(info os.FileInfo) *FileStat {
+	return &FileStat{
+		Name:    info.Name(),
+		Size:    info.Size(),
+		Mode:    info.Mode(),
+		ModTime: info.ModTime(),
+		IsDir:   info.IsDir(),
+	}
+}
+
+// ReadAtCloser is the interface returned by OpenReaderAt
+type ReadAtCloser interface {
+	io.ReaderAt
+	io.Closer
+}
+
+// ErrUnsupportedPlatform is returned by operations that are not available on the current OS
+var ErrUnsupportedPlatform = errors.New("operation is not supported on this platform")
+
+// Flush flushes any buffered log output so it isn't lost when the program exits
+// Outputs with a Flush() error method (i.e. *bufio.Writer) are flushed; files other than stdout/stderr are synced
+// For unbuffered outputs this is a no-op
+func Flush() error {
+	switch out := logger.Out.(type) {
+	case interface{ Flush() error }:
+		return out.Flush()
+	case *os.File:
+		if out == os.Stdout || out == os.Stderr {
+			return nil
+		}
+		return out.Sync()
+	}
+	return nil
+}
+
+// SetDefaultDirMode sets the mode used when a directory is created with a mode of 0
+// Setting 0 restores the built-in default of 0755
+func SetDefaultDirMode(mode os.FileMode) {
+	defaultDirMode = mode
+}
+
+// SetDefaultFileMode sets the mode used when a file is written with a mode of 0
+// Setting 0 restores the built-in default of 0644
+func SetDefaultFileMode(mode os.FileMode) {
+	defaultFileMode = mode
+}
 
 // SetLogger sets up a logrus instance
 func SetLogger(l *logrus.Logger) {
 	logger = l
 }
 
+// SetLogTimings controls whether the duration of heavier operations (copies, checksums, walks) is logged
+// Durations are logged at debug level in the "duration" field
+func SetLogTimings(enabled bool) {
+	logTimings = enabled
+}
+
+// SetRedactPaths controls whether paths are redacted in log fields
+// When enabled, the home directory prefix is replaced with ~ so usernames don't leak into shared logs
+// Only logging is affected; operations always use the full path
+func SetRedactPaths(redact bool) {
+	redactPaths = redact
+}
+
+// SetTempDir sets a fallback directory for WriteFileAtomic to stage writes in when the target directory isn't writable
+// Writes staged there are copied into place, so they are no longer atomic; an empty path disables the fallback (default)
+func SetTempDir(path string) {
+	fallbackTempDir = path
+}
+
 // SetVerbosity sets the verbosity for the filesystem package
 func SetVerbosity(v uint8) {
 	verbosity = v
 }
 
+// dirMode resolves the mode for a new directory, substituting the default when mode is 0
+func dirMode(mode os.FileMode) os.FileMode {
+	if mode != 0 {
+		return mode
+	}
+	if defaultDirMode != 0 {
+		return defaultDirMode
+	}
+	return 0755
+}
+
+// fileMode resolves the mode for a new file, substituting the default when mode is 0
+func fileMode(mode os.FileMode) os.FileMode {
+	if mode != 0 {
+		return mode
+	}
+	if defaultFileMode != 0 {
+		return defaultFileMode
+	}
+	return 0644
+}
+
+// logDuration logs how long an operation started at start took, when timing logs are enabled
+func logDuration(fields logrus.Fields, start time.Time, operation string) {
+	if logTimings {
+		logger.WithFields(fields).WithField("duration", time.Since(start)).Debug(operation + " finished")
+	}
+}
+
+// logPath returns path as it should appear in log fields
+func logPath(path string) string {
+	if !redactPaths {
+		return path
+	}
+	home, err := homedir.Dir()
+	if err != nil || home == "" || home == "/" {
+		return path
+	}
+	if path == home {
+		return "~"
+	}
+	if strings.HasPrefix(path, ForceTrailingSlash(home)) {
+		return "~/" + path[len(ForceTrailingSlash(home)):]
+	}
+	return path
+}
+
 // BuildAbsolutePathFromHome builds an absolute path (i.e. /home/user/example) from a home-based path (~/example)
 func BuildAbsolutePathFromHome(path string) (string, error) {
 	var err error
 	var fields = logrus.Fields{
-		"path":     path,
-		"expanded": path,
+		"path":     logPath(path),
+		"expanded": logPath(path),
 	}
 
 	logger.WithFields(fields).Debug("Expanding path")
@@ -42,6 +177,28 @@ func BuildAbsolutePathFromHome(path string) (string, error) {
 	return path, err
 }
 
+// CanWriteToDirectory returns when files can be created inside dir
+// Rather than inspecting permission bits (which can mislead on network and ACL-based filesystems),
+// a hidden temp file is created and removed
+func CanWriteToDirectory(dir string) bool {
+	var err error
+	dir, err = BuildAbsolutePathFromHome(dir)
+	if err != nil {
+		return false
+	}
+	var fields = logrus.Fields{
+		"directory": logPath(dir),
+	}
+
+	logger.WithFields(fields).Debug("Checking to see if directory is writable")
+	f, err := ioutil.TempFile(dir, ".filesystem-write-test-")
+	if err != nil {
+		return false
+	}
+	f.Close()
+	return os.Remove(f.Name()) == nil
+}
+
 // CheckExists checks to see if the provided path exists on the machine
 func CheckExists(path string) bool {
 	var err error
@@ -50,7 +207,7 @@ func CheckExists(path string) bool {
 		return false
 	}
 	var fields = logrus.Fields{
-		"path": path,
+		"path": logPath(path),
 	}
 
 	logger.WithFields(fields).Debug("Checking to see if path exists")
@@ -58,293 +215,2119 @@ func CheckExists(path string) bool {
 	return e == nil
 }
 
-// CreateDirectory creates a directory on the machine
-//   All children will be created (behavior matches mkdir -p)
-func CreateDirectory(path string) error {
+// CheckExistsContext checks to see if the provided path exists on the machine, honoring ctx
+// The stat runs in its own goroutine; if ctx is done first, ctx.Err() is returned.  On a hung mount the
+// goroutine lingers until the underlying call eventually returns
+func CheckExistsContext(ctx context.Context, path string) (bool, error) {
 	var err error
 	path, err = BuildAbsolutePathFromHome(path)
 	if err != nil {
-		return err
+		return false, err
 	}
 	var fields = logrus.Fields{
-		"path": path,
+		"path": logPath(path),
 	}
 
-	if isDirectory(path) {
-		return nil
-	}
-	logger.WithFields(fields).Debug("Creating directory")
-	err = os.MkdirAll(path, 0755)
-	if err != nil {
-		logger.WithFields(fields).Warn("Failed to create directory")
-		return err
+	logger.WithFields(fields).Debug("Checking to see if path exists")
+	_, err = statContext(ctx, path)
+	if err != nil && err == ctx.Err() {
+		logger.WithFields(fields).Warn("Gave up checking to see if path exists")
+		return false, err
 	}
-	logger.WithFields(fields).Debug("Directory created successfully")
-	return err
+	return err == nil, nil
 }
 
-func DeleteFile(path string) error {
+// CommonParent returns the longest directory prefix shared by all of paths
+// Paths are expanded and made absolute before being compared component by component, so /a/bc and /a/b share only /a
+// Paths aren't checked against the filesystem; a single path is its own common parent
+func CommonParent(paths []string) (string, error) {
 	var err error
-	path, err = BuildAbsolutePathFromHome(path)
-	if err != nil {
-		return err
+	var fields = logrus.Fields{
+		"paths": len(paths),
 	}
-	return os.Remove(path)
-}
 
-// ForceTrailingSlash forces a trailing slash at the end of the path
-// It will add the trailing slash only if one does not already exist
-func ForceTrailingSlash(path string) string {
-	if len(path) == 0 {
-		return "/"
+	logger.WithFields(fields).Debug("Finding common parent")
+	if len(paths) == 0 {
+		return "", errors.New("no paths provided")
 	}
 
-	if string(path[len(path)-1]) != "/" {
-		path += "/"
+	var volume string
+	var common []string
+	var separator = string(filepath.Separator)
+	for i, path := range paths {
+		path, err = BuildAbsolutePathFromHome(path)
+		if err != nil {
+			return "", err
+		}
+		path, err = filepath.Abs(path)
+		if err != nil {
+			return "", err
+		}
+
+		var vol = filepath.VolumeName(path)
+		var components = []string{}
+		if rest := strings.Trim(path[len(vol):], separator); rest != "" {
+			components = strings.Split(rest, separator)
+		}
+		if i == 0 {
+			volume = vol
+			common = components
+			continue
+		}
+		if vol != volume {
+			err = errors.New("paths do not share a volume: " + volume + ", " + vol)
+			logger.WithFields(fields).Warn("Failed to find common parent")
+			return "", err
+		}
+
+		var n = 0
+		for n < len(common) && n < len(components) && common[n] == components[n] {
+			n++
+		}
+		common = common[:n]
 	}
-	return path
+	return volume + separator + strings.Join(common, separator), nil
 }
 
-// GetDirectoryContents gets the files and folders inside the provided path
-func GetDirectoryContents(path string) ([]string, error) {
-	var err error
-	path, err = BuildAbsolutePathFromHome(path)
-	if err != nil {
+// ConcatFiles returns the contents of each file in paths, in order, joined with separator
+// Every path is checked before anything is read, so a missing file fails the whole call
+func ConcatFiles(paths []string, separator []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := ConcatFilesToWriter(&buf, paths, separator); err != nil {
 		return nil, err
 	}
+	return buf.Bytes(), nil
+}
+
+// ConcatFilesToWriter streams the contents of each file in paths, in order, to w, joined with separator, and
+// returns the number of bytes written
+// Every path is checked before anything is written, so a missing file fails the call without partial output;
+// read errors after that point may leave partial output in w
+func ConcatFilesToWriter(w io.Writer, paths []string, separator []byte) (int64, error) {
+	var err error
 	var fields = logrus.Fields{
-		"path": path,
+		"files": len(paths),
 	}
-	var fileNames = []string{}
-	var files []os.FileInfo
 
-	logger.WithFields(fields).Debug("Listing directory contents")
-	files, err = ioutil.ReadDir(path)
-	for _, f := range files {
-		fileNames = append(fileNames, f.Name())
+	logger.WithFields(fields).Debug("Concatenating files")
+	var expanded = make([]string, len(paths))
+	for i, path := range paths {
+		expanded[i], err = BuildAbsolutePathFromHome(path)
+		if err != nil {
+			return 0, err
+		}
+		if !isFile(expanded[i]) {
+			err = errors.New(expanded[i] + " is not a file")
+			logger.WithFields(fields).Warn("Failed to concatenate files")
+			return 0, err
+		}
 	}
-	return fileNames, err
-}
 
-// GetFileExtension returns the extension for the file passed in
-func GetFileExtension(path string) string {
-	var ext = filepath.Ext(path)
-	if len(ext) > 0 {
-		return ext[1:]
+	var written int64
+	for i, path := range expanded {
+		if i > 0 && len(separator) > 0 {
+			n, err := w.Write(separator)
+			written += int64(n)
+			if err != nil {
+				logger.WithFields(fields).Warn("Failed to concatenate files")
+				return written, err
+			}
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			logger.WithFields(fields).Warn("Failed to concatenate files")
+			return written, err
+		}
+		n, err := io.Copy(w, f)
+		f.Close()
+		written += n
+		if err != nil {
+			logger.WithFields(fields).Warn("Failed to concatenate files")
+			return written, err
+		}
 	}
-	return ""
+	fields["bytes"] = written
+	logger.WithFields(fields).Debug("Files concatenated successfully")
+	return written, nil
 }
 
-// GetFileSHA256Checksum gets the SHA-256 checksum of the file as a hex string
-//   Output matches sha256sum (Linux) / shasum -a 256 (OSX)
-func GetFileSHA256Checksum(path string) (string, error) {
+// CreateDirectory creates a directory on the machine
+//   All children will be created (behavior matches mkdir -p)
+//   Directories are created with the default directory mode (see SetDefaultDirMode)
+func CreateDirectory(path string) error {
+	return CreateDirectoryWithMode(path, 0)
+}
+
+// CreateDirectorySecure creates a directory on the machine after verifying that path (if it exists) and each
+// existing ancestor is owned by expectedUID or root, so nothing is created under a directory another user controls
+// Directories writable by group or other users are rejected unless they have the sticky bit set (like /tmp)
+// An *ErrUnexpectedOwner or *ErrInsecureDirectory is returned for the first entry that fails; entries are checked
+// without following symlinks, and path is checked again once it has been created
+// The checks and the creation aren't atomic: an ancestor replaced in between isn't detected, and in a sticky
+// directory another user can create path first (caught by the final check, after CreateDirectory has run)
+// Only supported on Linux and macOS
+func CreateDirectorySecure(path string, expectedUID int) error {
 	var err error
 	path, err = BuildAbsolutePathFromHome(path)
 	if err != nil {
-		return "", err
+		return err
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return err
 	}
 	var fields = logrus.Fields{
-		"path": path,
+		"path":        logPath(path),
+		"expectedUID": expectedUID,
 	}
 
-	if err == nil {
-		if isFile(path) {
-			if f, err := os.Open(path); err == nil {
-				defer f.Close()
-
-				hasher := sha256.New()
-				if _, err := io.Copy(hasher, f); err == nil {
-					checksumString := hex.EncodeToString(hasher.Sum(nil))
-					fields = logrus.Fields{
-						"path":     path,
-						"checksum": checksumString,
-					}
-					logger.WithFields(fields).Debug("Computed file checksum")
-					return checksumString, err
-				}
-			}
-		} else {
-			err = errors.New(path + " is not a file")
+	logger.WithFields(fields).Debug("Verifying directory ownership")
+	for dir := path; ; dir = filepath.Dir(dir) {
+		if err = verifyDirectoryTrusted(dir, expectedUID); os.IsNotExist(err) {
+			err = nil
+		}
+		if err != nil {
+			logger.WithFields(fields).Warn("Failed to verify directory ownership")
+			return err
+		}
+		if dir == filepath.Dir(dir) {
+			break
 		}
 	}
-	logger.WithFields(fields).Warn("Failed to retreive file checksum")
-	return "", err
+	if err = CreateDirectory(path); err != nil {
+		return err
+	}
+	if err = verifyDirectoryTrusted(path, expectedUID); err != nil {
+		logger.WithFields(fields).Warn("Failed to verify directory ownership")
+		return err
+	}
+	return nil
 }
 
-// IsDirectory returns when path exists and is a directory
-// supports ~ expansion
-func IsDirectory(path string) bool {
-	var err error
-	path, err = BuildAbsolutePathFromHome(path)
+// verifyDirectoryTrusted checks that the entry at dir is owned by expectedUID or root and, if it's a directory,
+// that other users can't write to it (unless it's sticky)
+func verifyDirectoryTrusted(dir string, expectedUID int) error {
+	info, err := os.Lstat(dir)
 	if err != nil {
-		return false
+		return err
 	}
-	var fields = logrus.Fields{
-		"path": path,
+	owner, err := fileOwner(info)
+	if err != nil {
+		return err
 	}
-
-	logger.WithFields(fields).Debug("Checking to see if path is a directory")
-	return isDirectory(path)
-}
-
-// Check to see if the path provided is a directory
-func isDirectory(path string) bool {
-	stat, err := os.Stat(path)
-	return !os.IsNotExist(err) && stat.IsDir()
+	if owner != expectedUID && owner != 0 {
+		return &ErrUnexpectedOwner{Path: dir, Expected: expectedUID, Actual: owner}
+	}
+	if info.IsDir() && info.Mode().Perm()&0022 != 0 && info.Mode()&os.ModeSticky == 0 {
+		return &ErrInsecureDirectory{Path: dir, Mode: info.Mode()}
+	}
+	return nil
 }
 
-// IsEmptyDirectory returns when path exists and is an empty directory
-// supports ~ expansion
-func IsEmptyDirectory(path string) bool {
+// CreateDirectoryWithExactMode creates a directory on the machine with exactly the requested mode
+// Unlike CreateDirectoryWithMode, the process umask is not applied; every directory created by
+// this call is chmod'ed to mode after creation.  Directories that already exist are left untouched
+// A mode of 0 uses the default directory mode (see SetDefaultDirMode)
+func CreateDirectoryWithExactMode(path string, mode os.FileMode) error {
 	var err error
+	mode = dirMode(mode)
 	path, err = BuildAbsolutePathFromHome(path)
 	if err != nil {
-		return false
+		return err
 	}
 	var fields = logrus.Fields{
-		"path": path,
+		"path": logPath(path),
+		"mode": mode,
 	}
 
-	logger.WithFields(fields).Debug("Checking to see if path is an empty directory")
+	if isDirectory(path) {
+		return nil
+	}
 
-	if file, err := os.Open(path); err == nil {
-		defer file.Close()
-		if !isDirectory(path) {
-			return false
+	// Collect the directories that don't exist yet so only those get their mode changed
+	var created = []string{}
+	for dir := filepath.Clean(path); !CheckExists(dir); dir = filepath.Dir(dir) {
+		created = append(created, dir)
+		if dir == filepath.Dir(dir) {
+			break
 		}
-
-		contents, err := file.Readdir(1)
-		return (err == nil || err == io.EOF) && len(contents) == 0
 	}
-	return false
-}
 
-// IsFile returns when path exists and is a file
-// supports ~ expansion
-func IsFile(path string) bool {
-	var err error
-	path, err = BuildAbsolutePathFromHome(path)
-	if err != nil {
-		return false
+	if err = CreateDirectoryWithMode(path, mode); err != nil {
+		return err
 	}
-	var fields = logrus.Fields{
-		"path": path,
+	for i := len(created) - 1; i >= 0; i-- {
+		if err = os.Chmod(created[i], mode); err != nil {
+			logger.WithFields(fields).Warn("Failed to set directory mode")
+			return err
+		}
 	}
-
-	logger.WithFields(fields).Debug("Checking to see if path is a file")
-	return isFile(path)
-}
-
-// isFile checks to see if the file exists on the filesystem
-func isFile(path string) bool {
-	stat, err := os.Stat(path)
-	return !os.IsNotExist(err) && !stat.IsDir()
+	logger.WithFields(fields).Debug("Directory mode set successfully")
+	return nil
 }
 
-// LoadFileBytes loads the contents of path into a []byte if the file exists
-func LoadFileBytes(path string) ([]byte, error) {
+// CreateDirectoryWithMode creates a directory on the machine with the provided mode
+// All children will be created (behavior matches mkdir -p)
+// The process umask is applied to mode; use CreateDirectoryWithExactMode to bypass it
+// A mode of 0 uses the default directory mode (see SetDefaultDirMode)
+func CreateDirectoryWithMode(path string, mode os.FileMode) error {
 	var err error
+	mode = dirMode(mode)
 	path, err = BuildAbsolutePathFromHome(path)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	var fields = logrus.Fields{
-		"file": path,
+		"path": logPath(path),
+		"mode": mode,
 	}
 
-	logger.WithFields(fields).Debug("Attempting to load file")
-
-	if isFile(path) {
-		contents, err := ioutil.ReadFile(path)
-		if err == nil {
-			logger.WithFields(fields).Debug("File read successfully")
-			return contents, err
-		}
-	} else {
-		err = errors.New(path + " is not a file")
+	if isDirectory(path) {
+		return nil
 	}
-	logger.WithFields(fields).Info("Could not read file")
-	return []byte{}, err
-}
-
-// LoadFileIfExists is deprecated in favor of LoadFileString
-func LoadFileIfExists(path string) (string, error) {
-	return LoadFileString(path)
+	logger.WithFields(fields).Debug("Creating directory")
+	err = os.MkdirAll(path, mode)
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to create directory")
+		return err
+	}
+	logger.WithFields(fields).Debug("Directory created successfully")
+	return err
 }
 
-// LoadFileString loads the contents of path into a string if the file exists
-func LoadFileString(path string) (string, error) {
+// CreateSparseFile creates a file at path with a logical size of size bytes without writing any data
+// On filesystems that support sparse files, the file takes up (almost) no space on disk until written to
+// A mode of 0 uses the default file mode (see SetDefaultFileMode)
+func CreateSparseFile(path string, size int64, mode os.FileMode) error {
 	var err error
+	mode = fileMode(mode)
 	path, err = BuildAbsolutePathFromHome(path)
 	if err != nil {
-		return "", err
+		return err
 	}
 	var fields = logrus.Fields{
-		"file": path,
+		"filename": logPath(path),
+		"mode":     mode,
+		"size":     size,
 	}
 
-	logger.WithFields(fields).Debug("Attempting to load file")
-	if isFile(path) {
-		contents, err := ioutil.ReadFile(path)
-		if err == nil {
-			logger.WithFields(fields).Debug("File read successfully")
-			return string(contents), err
+	logger.WithFields(fields).Debug("Creating sparse file")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err == nil {
+		err = f.Truncate(size)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
 		}
-	} else {
-		err = errors.New(path + " is not a file")
 	}
-	logger.WithFields(fields).Info("Could not read file")
-	return "", err
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to create sparse file")
+		return err
+	}
+	logger.WithFields(fields).Debug("Sparse file created successfully")
+	return nil
 }
 
-// RemoveDirectory removes the directory at path from the system
-// If recursive is set to true, it will remove all children as well
-func RemoveDirectory(path string, recursive bool) error {
-	var err error
-	path, err = BuildAbsolutePathFromHome(path)
+// CreateTree creates each directory in dirs and writes each file in files under root, creating root and any
+// parent directories as needed; all paths are relative to root and must pass IsSafeRelativePath
+// If anything fails, the files and directories created so far are removed again; existing files that were
+// overwritten are not restored
+// Files are written with the default file mode (see SetDefaultFileMode)
+func CreateTree(root string, files map[string][]byte, dirs []string) (err error) {
+	root, err = BuildAbsolutePathFromHome(root)
 	if err != nil {
 		return err
 	}
-
 	var fields = logrus.Fields{
-		"directory": path,
+		"root":  logPath(root),
+		"files": len(files),
+		"dirs":  len(dirs),
 	}
 
-	logger.WithFields(fields).Debug("Attempting to remove directory")
-	if isDirectory(path) {
-		if recursive {
+	logger.WithFields(fields).Debug("Creating directory tree")
+	for _, dir := range dirs {
+		if !IsSafeRelativePath(dir) {
+			err = errors.New(dir + " is not a safe relative path")
+			logger.WithFields(fields).Warn("Failed to create directory tree")
+			return err
+		}
+	}
+	for name := range files {
+		if !IsSafeRelativePath(name) {
+			err = errors.New(name + " is not a safe relative path")
+			logger.WithFields(fields).Warn("Failed to create directory tree")
+			return err
+		}
+	}
+
+	// Everything created is recorded in order, so it can be removed deepest first on failure
+	var created []string
+	defer func() {
+		if err != nil {
+			for i := len(created) - 1; i >= 0; i-- {
+				os.Remove(created[i])
+			}
+			logger.WithFields(fields).Warn("Failed to create directory tree")
+		}
+	}()
+	var mkdir = func(dir string) error {
+		var missing []string
+		for d := dir; ; d = filepath.Dir(d) {
+			if _, err := os.Lstat(d); err == nil || d == filepath.Dir(d) {
+				break
+			}
+			missing = append(missing, d)
+		}
+		if err := CreateDirectory(dir); err != nil {
+			return err
+		}
+		for i := len(missing) - 1; i >= 0; i-- {
+			created = append(created, missing[i])
+		}
+		return nil
+	}
+
+	if err = mkdir(root); err != nil {
+		return err
+	}
+	for _, dir := range dirs {
+		if err = mkdir(filepath.Join(root, dir)); err != nil {
+			return err
+		}
+	}
+	for name, data := range files {
+		var path = filepath.Join(root, name)
+		if err = mkdir(filepath.Dir(path)); err != nil {
+			return err
+		}
+		var existed = CheckExists(path)
+		if err = WriteFile(path, data, 0); err != nil {
+			return err
+		}
+		if !existed {
+			created = append(created, path)
+		}
+	}
+	logger.WithFields(fields).Debug("Directory tree created successfully")
+	return nil
+}
+
+func DeleteFile(path string) error {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// ExpandUserPath expands a leading ~ or ~username in path to the matching home directory
+// A bare ~ behaves like BuildAbsolutePathFromHome; unknown users return a user.UnknownUserError
+func ExpandUserPath(path string) (string, error) {
+	var fields = logrus.Fields{
+		"path": logPath(path),
+	}
+	if !strings.HasPrefix(path, "~") {
+		return path, nil
+	}
+
+	var name = path[1:]
+	var rest = ""
+	if i := strings.IndexAny(name, `/\`); i >= 0 {
+		name, rest = name[:i], name[i:]
+	}
+	if name == "" {
+		return BuildAbsolutePathFromHome(path)
+	}
+
+	logger.WithFields(fields).Debug("Expanding user path")
+	u, err := user.Lookup(name)
+	if err != nil {
+		logger.WithFields(fields).Error("Could not expand user path")
+		return path, err
+	}
+	return filepath.Join(u.HomeDir, rest), nil
+}
+
+// FilterDirectory returns the entries inside the provided path for which pred returns true
+func FilterDirectory(path string, pred func(os.FileInfo) bool) ([]os.FileInfo, error) {
+	var matches = []os.FileInfo{}
+	err := IterateDirectory(path, func(info os.FileInfo) error {
+		if pred(info) {
+			matches = append(matches, info)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// ForceTrailingSlash forces a trailing slash at the end of the path
+// It will add the trailing slash only if one does not already exist
+func ForceTrailingSlash(path string) string {
+	if len(path) == 0 {
+		return "/"
+	}
+
+	if string(path[len(path)-1]) != "/" {
+		path += "/"
+	}
+	return path
+}
+
+// GetCanonicalCase returns path with each component in the case it is stored with on disk
+// On case-insensitive filesystems (macOS, Windows) a path like ~/documents resolves to ~/Documents; each parent
+// directory is listed and the entry matching the component case-insensitively is used
+func GetCanonicalCase(path string) (string, error) {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return "", err
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	var fields = logrus.Fields{
+		"path": logPath(path),
+	}
+
+	logger.WithFields(fields).Debug("Resolving canonical case")
+	var separator = string(filepath.Separator)
+	var volume = filepath.VolumeName(path)
+	var canonical = volume + separator
+	for _, component := range strings.Split(strings.Trim(path[len(volume):], separator), separator) {
+		if component == "" {
+			continue
+		}
+		var names []string
+		names, err = GetDirectoryContents(canonical)
+		if err != nil {
+			logger.WithFields(fields).Warn("Failed to resolve canonical case")
+			return "", err
+		}
+		var match = ""
+		for _, name := range names {
+			if name == component {
+				match = name
+				break
+			}
+			if match == "" && strings.EqualFold(name, component) {
+				match = name
+			}
+		}
+		if match == "" {
+			err = &os.PathError{Op: "resolve", Path: filepath.Join(canonical, component), Err: os.ErrNotExist}
+			logger.WithFields(fields).Warn("Failed to resolve canonical case")
+			return "", err
+		}
+		canonical = filepath.Join(canonical, match)
+	}
+	return canonical, nil
+}
+
+// GetDirectoryContents gets the files and folders inside the provided path
+func GetDirectoryContents(path string) ([]string, error) {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return nil, err
+	}
+	var fields = logrus.Fields{
+		"path": logPath(path),
+	}
+	var fileNames = []string{}
+	var files []os.FileInfo
+
+	logger.WithFields(fields).Debug("Listing directory contents")
+	files, err = ioutil.ReadDir(path)
+	for _, f := range files {
+		fileNames = append(fileNames, f.Name())
+	}
+	return fileNames, err
+}
+
+// GetDirectoryContentsAbsolute gets the files and folders inside the provided path as full paths
+// Each entry is joined to the expanded directory, so results can be passed directly to other functions
+func GetDirectoryContentsAbsolute(path string) ([]string, error) {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return nil, err
+	}
+	names, err := GetDirectoryContents(path)
+	var paths = make([]string, len(names))
+	for i, name := range names {
+		paths[i] = filepath.Join(path, name)
+	}
+	return paths, err
+}
+
+// GetDirectoryContentsGrouped gets the folders and files inside the provided path as separate lists, each sorted by name
+// Entries are not followed, so a symlink to a directory is listed with the files
+func GetDirectoryContentsGrouped(path string) (dirs, files []string, err error) {
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var fields = logrus.Fields{
+		"path": logPath(path),
+	}
+
+	logger.WithFields(fields).Debug("Listing directory contents")
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to list directory contents")
+		return nil, nil, err
+	}
+	// ReadDir returns entries sorted by name, so each group stays sorted
+	dirs, files = []string{}, []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dirs = append(dirs, entry.Name())
+		} else {
+			files = append(files, entry.Name())
+		}
+	}
+	return dirs, files, nil
+}
+
+// GetFileExtension returns the extension for the file passed in
+// Only the final path component is considered, so dots in directory names are ignored
+func GetFileExtension(path string) string {
+	var ext = filepath.Ext(filepath.Base(path))
+	if len(ext) > 0 {
+		return ext[1:]
+	}
+	return ""
+}
+
+// GetFileExtensionNormalized returns the lowercased extension for the file passed in (photo.JPG => jpg)
+func GetFileExtensionNormalized(path string) string {
+	return strings.ToLower(GetFileExtension(path))
+}
+
+// GetFileSHA256Checksum gets the SHA-256 checksum of the file as a hex string
+//   Output matches sha256sum (Linux) / shasum -a 256 (OSX)
+// When the checksum cache is enabled (see EnableChecksumCache), unchanged files aren't read again; checks within
+// this package that verify contents (i.e. CopyFileVerified, WriteFileIfChanged) always read the file
+func GetFileSHA256Checksum(path string) (string, error) {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return "", err
+	}
+	var fields = logrus.Fields{
+		"path": logPath(path),
+	}
+	defer logDuration(fields, time.Now(), "Checksum")
+
+	info, err := os.Stat(path)
+	if err == nil && info.IsDir() {
+		err = errors.New(path + " is not a file")
+	}
+	if err == nil {
+		if checksumString, ok := cachedChecksum(path, info); ok {
+			fields["checksum"] = checksumString
+			logger.WithFields(fields).Debug("Using cached file checksum")
+			return checksumString, nil
+		}
+		var checksumString string
+		if checksumString, err = fileSHA256Checksum(path); err == nil {
+			storeChecksum(path, info, checksumString)
+			fields["checksum"] = checksumString
+			logger.WithFields(fields).Debug("Computed file checksum")
+			return checksumString, nil
+		}
+	}
+	logger.WithFields(fields).Warn("Failed to retreive file checksum")
+	return "", err
+}
+
+// IsBinaryFile returns when the file at path appears to contain binary data
+// Like git, a file is considered binary when a NUL byte appears within its first 8000 bytes
+func IsBinaryFile(path string) (bool, error) {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return false, err
+	}
+	var fields = logrus.Fields{
+		"path": logPath(path),
+	}
+
+	logger.WithFields(fields).Debug("Checking to see if file is binary")
+	if !isFile(path) {
+		return false, errors.New(path + " is not a file")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	var header = make([]byte, 8000)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	return bytes.IndexByte(header[:n], 0) >= 0, nil
+}
+
+// IsCaseSensitive reports whether the filesystem containing dir treats names differing only in case as distinct
+// A temp file is created in dir, then a second one whose name differs only in case; if both can exist, the
+// filesystem is case-sensitive.  Both files are removed afterward
+func IsCaseSensitive(dir string) (bool, error) {
+	var err error
+	dir, err = BuildAbsolutePathFromHome(dir)
+	if err != nil {
+		return false, err
+	}
+	var fields = logrus.Fields{
+		"path": logPath(dir),
+	}
+
+	logger.WithFields(fields).Debug("Checking to see if filesystem is case-sensitive")
+	lower, err := ioutil.TempFile(dir, ".casetest-")
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to check case sensitivity")
+		return false, err
+	}
+	lower.Close()
+	defer os.Remove(lower.Name())
+
+	var upper = filepath.Join(dir, strings.ToUpper(filepath.Base(lower.Name())))
+	f, err := os.OpenFile(upper, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		fields["caseSensitive"] = false
+		logger.WithFields(fields).Debug("Checked case sensitivity")
+		return false, nil
+	}
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to check case sensitivity")
+		return false, err
+	}
+	f.Close()
+	os.Remove(upper)
+	fields["caseSensitive"] = true
+	logger.WithFields(fields).Debug("Checked case sensitivity")
+	return true, nil
+}
+
+// IsDirectory returns when path exists and is a directory
+// supports ~ expansion
+func IsDirectory(path string) bool {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return false
+	}
+	var fields = logrus.Fields{
+		"path": logPath(path),
+	}
+
+	logger.WithFields(fields).Debug("Checking to see if path is a directory")
+	return isDirectory(path)
+}
+
+// Check to see if the path provided is a directory
+func isDirectory(path string) bool {
+	stat, err := os.Stat(path)
+	return !os.IsNotExist(err) && stat.IsDir()
+}
+
+// IsEmptyDirectory returns when path exists and is an empty directory
+// supports ~ expansion
+func IsEmptyDirectory(path string) bool {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return false
+	}
+	var fields = logrus.Fields{
+		"path": logPath(path),
+	}
+
+	logger.WithFields(fields).Debug("Checking to see if path is an empty directory")
+
+	if file, err := os.Open(path); err == nil {
+		defer file.Close()
+		if !isDirectory(path) {
+			return false
+		}
+
+		contents, err := file.Readdir(1)
+		return (err == nil || err == io.EOF) && len(contents) == 0
+	}
+	return false
+}
+
+// IsFile returns when path exists and is a file
+// supports ~ expansion
+func IsFile(path string) bool {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return false
+	}
+	var fields = logrus.Fields{
+		"path": logPath(path),
+	}
+
+	logger.WithFields(fields).Debug("Checking to see if path is a file")
+	return isFile(path)
+}
+
+// IsFileContext returns when path exists and is a file, honoring ctx
+// supports ~ expansion
+// The stat runs in its own goroutine; if ctx is done first, ctx.Err() is returned.  On a hung mount the
+// goroutine lingers until the underlying call eventually returns
+func IsFileContext(ctx context.Context, path string) (bool, error) {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return false, err
+	}
+	var fields = logrus.Fields{
+		"path": logPath(path),
+	}
+
+	logger.WithFields(fields).Debug("Checking to see if path is a file")
+	stat, err := statContext(ctx, path)
+	if err != nil && err == ctx.Err() {
+		logger.WithFields(fields).Warn("Gave up checking to see if path is a file")
+		return false, err
+	}
+	return err == nil && !stat.IsDir(), nil
+}
+
+// isFile checks to see if the file exists on the filesystem
+func isFile(path string) bool {
+	stat, err := os.Stat(path)
+	return !os.IsNotExist(err) && !stat.IsDir()
+}
+
+// IsPathTooLong reports whether path, once expanded, is longer than the platform's path length limit (PATH_MAX)
+// or has a component longer than its file name limit (NAME_MAX), so callers can warn before hitting ENAMETOOLONG
+// Relative paths are checked as given; the kernel doesn't count the working directory against the limit
+func IsPathTooLong(path string) bool {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return false
+	}
+	var fields = logrus.Fields{
+		"path": logPath(path),
+	}
+
+	logger.WithFields(fields).Debug("Checking path length")
+	maxPath, maxName := pathLimits()
+	if len(path) >= maxPath {
+		logger.WithFields(fields).Info("Path is too long")
+		return true
+	}
+	for _, component := range strings.Split(path, string(filepath.Separator)) {
+		if len(component) > maxName {
+			logger.WithFields(fields).Info("Path component is too long")
+			return true
+		}
+	}
+	return false
+}
+
+// IsSafeRelativePath reports whether path is a non-empty relative path that stays inside the directory it's joined to
+// Absolute paths, paths with a volume name, and paths with any .. component (even ones that would be cleaned away)
+// are rejected; both / and \ are treated as separators so input from other platforms can't slip through
+func IsSafeRelativePath(path string) bool {
+	if path == "" || filepath.IsAbs(path) || filepath.VolumeName(path) != "" || strings.HasPrefix(path, "/") || strings.HasPrefix(path, "\\") {
+		return false
+	}
+	for _, component := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if component == ".." {
+			return false
+		}
+	}
+	return filepath.Clean(path) != "."
+}
+
+// IterateDirectory calls fn for each entry inside the provided path
+// Entries are read in batches as they're processed, so large directories are never held in memory at once
+// Iteration stops with the error returned by fn, if any
+func IterateDirectory(path string, fn func(info os.FileInfo) error) error {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return err
+	}
+	var fields = logrus.Fields{
+		"path": logPath(path),
+	}
+
+	logger.WithFields(fields).Debug("Iterating directory contents")
+	dir, err := os.Open(path)
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to iterate directory")
+		return err
+	}
+	defer dir.Close()
+
+	for {
+		entries, err := dir.Readdir(256)
+		for _, entry := range entries {
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			logger.WithFields(fields).Warn("Failed to iterate directory")
+			return err
+		}
+	}
+}
+
+// statContext stats path in a separate goroutine so a hung call can be abandoned when ctx is done
+func statContext(ctx context.Context, path string) (os.FileInfo, error) {
+	type result struct {
+		info os.FileInfo
+		err  error
+	}
+	// Buffered so the goroutine can always deliver its result and exit, even after being abandoned
+	var done = make(chan result, 1)
+	var stat = osStat
+	go func() {
+		info, err := stat(path)
+		done <- result{info, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.info, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// LoadFileBytes loads the contents of path into a []byte if the file exists
+func LoadFileBytes(path string) ([]byte, error) {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return nil, err
+	}
+	var fields = logrus.Fields{
+		"file": logPath(path),
+	}
+
+	logger.WithFields(fields).Debug("Attempting to load file")
+
+	if isFile(path) {
+		var contents []byte
+		contents, err = ioutil.ReadFile(path)
+		if err == nil {
+			logger.WithFields(fields).Debug("File read successfully")
+			return contents, err
+		}
+	} else {
+		err = errors.New(path + " is not a file")
+	}
+	logger.WithFields(fields).Info("Could not read file")
+	return []byte{}, err
+}
+
+// LoadFileBytesTimeout loads the contents of path like LoadFileBytes, returning ErrTimeout if the read doesn't
+// complete within timeout (i.e. a FIFO with no writer or a stuck network mount)
+// A read that times out can't be cancelled, so its goroutine is leaked until the underlying read returns
+func LoadFileBytesTimeout(path string, timeout time.Duration) ([]byte, error) {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return nil, err
+	}
+	var fields = logrus.Fields{
+		"file":    logPath(path),
+		"timeout": timeout,
+	}
+
+	type result struct {
+		contents []byte
+		err      error
+	}
+	logger.WithFields(fields).Debug("Attempting to load file with timeout")
+	// Buffered so the goroutine can always deliver its result and exit, even after being abandoned
+	var done = make(chan result, 1)
+	var stat, open = osStat, osOpen
+	go func() {
+		if info, err := stat(path); err != nil || info.IsDir() {
+			done <- result{[]byte{}, errors.New(path + " is not a file")}
+			return
+		}
+		f, err := open(path)
+		if err != nil {
+			done <- result{[]byte{}, err}
+			return
+		}
+		defer f.Close()
+		contents, err := ioutil.ReadAll(f)
+		done <- result{contents, err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			logger.WithFields(fields).Info("Could not read file")
+			return []byte{}, r.err
+		}
+		logger.WithFields(fields).Debug("File read successfully")
+		return r.contents, nil
+	case <-time.After(timeout):
+		logger.WithFields(fields).Warn("Timed out reading file")
+		return []byte{}, ErrTimeout
+	}
+}
+
+// LoadFileIfExists is deprecated in favor of LoadFileString
+func LoadFileIfExists(path string) (string, error) {
+	return LoadFileString(path)
+}
+
+// LoadFileOrEmpty loads the contents of path like LoadFileBytes, treating a missing file as empty
+// Other failures (i.e. permission problems, or path being a directory) are still returned
+func LoadFileOrEmpty(path string) ([]byte, error) {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = os.Stat(path); os.IsNotExist(err) {
+		logger.WithFields(logrus.Fields{"file": logPath(path)}).Debug("File does not exist; treating as empty")
+		return []byte{}, nil
+	}
+	return LoadFileBytes(path)
+}
+
+// LoadFileOrReader loads the contents of path like LoadFileBytes, or reads all of fallback when path is "-"
+// This matches the common CLI convention of "-" meaning stdin
+func LoadFileOrReader(path string, fallback io.Reader) ([]byte, error) {
+	if path != "-" {
+		return LoadFileBytes(path)
+	}
+
+	logger.Debug("Reading contents from fallback reader")
+	if fallback == nil {
+		return []byte{}, errors.New("no reader provided for -")
+	}
+	contents, err := ioutil.ReadAll(fallback)
+	if err != nil {
+		logger.Info("Could not read from fallback reader")
+		return []byte{}, err
+	}
+	return contents, nil
+}
+
+// LoadFileString loads the contents of path into a string if the file exists
+func LoadFileString(path string) (string, error) {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return "", err
+	}
+	var fields = logrus.Fields{
+		"file": logPath(path),
+	}
+
+	logger.WithFields(fields).Debug("Attempting to load file")
+	if isFile(path) {
+		var contents []byte
+		contents, err = ioutil.ReadFile(path)
+		if err == nil {
+			logger.WithFields(fields).Debug("File read successfully")
+			return string(contents), err
+		}
+	} else {
+		err = errors.New(path + " is not a file")
+	}
+	logger.WithFields(fields).Info("Could not read file")
+	return "", err
+}
+
+// LoadFileVerified loads the contents of path into a []byte if its SHA-256 checksum matches expectedSHA256
+// The checksum is computed while the file is read; an *ErrChecksumMismatch is returned on mismatch
+func LoadFileVerified(path, expectedSHA256 string) ([]byte, error) {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return nil, err
+	}
+	var fields = logrus.Fields{
+		"file":     logPath(path),
+		"expected": expectedSHA256,
+	}
+
+	logger.WithFields(fields).Debug("Attempting to load and verify file")
+	if !isFile(path) {
+		err = errors.New(path + " is not a file")
+		logger.WithFields(fields).Info("Could not read file")
+		return []byte{}, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		logger.WithFields(fields).Info("Could not read file")
+		return []byte{}, err
+	}
+	defer f.Close()
+
+	var contents bytes.Buffer
+	hasher := sha256.New()
+	if _, err = io.Copy(io.MultiWriter(&contents, hasher), f); err != nil {
+		logger.WithFields(fields).Info("Could not read file")
+		return []byte{}, err
+	}
+
+	checksum := hex.EncodeToString(hasher.Sum(nil))
+	if !strings.EqualFold(checksum, expectedSHA256) {
+		fields["checksum"] = checksum
+		logger.WithFields(fields).Warn("File checksum does not match")
+		return []byte{}, &ErrChecksumMismatch{Path: path, Expected: expectedSHA256, Actual: checksum}
+	}
+	logger.WithFields(fields).Debug("File read and verified successfully")
+	return contents.Bytes(), nil
+}
+
+// MoveFileIntoDirectory moves the file at src into destDir, keeping its name, and returns the new path
+// destDir is created if needed; an existing file at the new path is only replaced when overwrite is set,
+// otherwise an error satisfying os.IsExist is returned, even if the file appears while the move is in progress
+// Moves across filesystems fall back to a copy followed by removing src
+func MoveFileIntoDirectory(src, destDir string, overwrite bool) (string, error) {
+	var err error
+	src, err = BuildAbsolutePathFromHome(src)
+	if err != nil {
+		return "", err
+	}
+	destDir, err = BuildAbsolutePathFromHome(destDir)
+	if err != nil {
+		return "", err
+	}
+	var target = filepath.Join(destDir, filepath.Base(src))
+	var fields = logrus.Fields{
+		"source":      logPath(src),
+		"destination": logPath(target),
+		"overwrite":   overwrite,
+	}
+
+	logger.WithFields(fields).Debug("Moving file into directory")
+	if !isFile(src) {
+		err = errors.New(src + " is not a file")
+	} else if err = CreateDirectory(destDir); err == nil {
+		if overwrite {
+			err = moveFile(src, target)
+		} else {
+			err = moveFileNoReplace(src, target)
+		}
+	}
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to move file")
+		return "", err
+	}
+	logger.WithFields(fields).Debug("File moved successfully")
+	return target, nil
+}
+
+// NewestFile returns the path and info of the most recently modified file inside dir
+// Subdirectories are ignored; an *ErrEmptyDirectory is returned if dir contains no files
+func NewestFile(dir string) (string, os.FileInfo, error) {
+	return findFileByModTime(dir, true)
+}
+
+// NormalizePaths expands, cleans, and makes each path absolute, resolving symlinks for paths that exist, and removes
+// duplicates (including different paths that resolve to the same file) while preserving the order of first appearance
+// Paths that don't exist are kept in their cleaned absolute form
+func NormalizePaths(paths []string) ([]string, error) {
+	var fields = logrus.Fields{
+		"paths": len(paths),
+	}
+
+	logger.WithFields(fields).Debug("Normalizing paths")
+	var normalized = []string{}
+	var seen = map[string]bool{}
+	for _, path := range paths {
+		path, err := BuildAbsolutePathFromHome(path)
+		if err == nil {
+			path, err = filepath.Abs(path)
+		}
+		if err != nil {
+			logger.WithFields(fields).Warn("Failed to normalize paths")
+			return nil, err
+		}
+		if resolved, err := filepath.EvalSymlinks(path); err == nil {
+			path = resolved
+		}
+		if !seen[path] {
+			seen[path] = true
+			normalized = append(normalized, path)
+		}
+	}
+	fields["unique"] = len(normalized)
+	logger.WithFields(fields).Debug("Paths normalized")
+	return normalized, nil
+}
+
+// OldestFile returns the path and info of the least recently modified file inside dir
+// Subdirectories are ignored; an *ErrEmptyDirectory is returned if dir contains no files
+func OldestFile(dir string) (string, os.FileInfo, error) {
+	return findFileByModTime(dir, false)
+}
+
+// findFileByModTime scans dir for the file with the newest (or oldest) modification time
+func findFileByModTime(dir string, newest bool) (string, os.FileInfo, error) {
+	var err error
+	dir, err = BuildAbsolutePathFromHome(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	var fields = logrus.Fields{
+		"directory": logPath(dir),
+		"newest":    newest,
+	}
+
+	logger.WithFields(fields).Debug("Searching directory by modification time")
+	var found os.FileInfo
+	err = IterateDirectory(dir, func(info os.FileInfo) error {
+		if info.IsDir() {
+			return nil
+		}
+		if found == nil || (newest && info.ModTime().After(found.ModTime())) || (!newest && info.ModTime().Before(found.ModTime())) {
+			found = info
+		}
+		return nil
+	})
+	if err == nil && found == nil {
+		err = &ErrEmptyDirectory{Path: dir}
+	}
+	if err != nil {
+		logger.WithFields(fields).Info("Could not find file by modification time")
+		return "", nil, err
+	}
+	return filepath.Join(dir, found.Name()), found, nil
+}
+
+// OpenReaderAt opens path for random access reads and returns the handle along with the file size
+// The caller is responsible for closing the handle
+func OpenReaderAt(path string) (ReadAtCloser, int64, error) {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	var fields = logrus.Fields{
+		"file": logPath(path),
+	}
+
+	logger.WithFields(fields).Debug("Opening file for random access")
+	if !isFile(path) {
+		err = errors.New(path + " is not a file")
+		logger.WithFields(fields).Info("Could not open file")
+		return nil, 0, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		logger.WithFields(fields).Info("Could not open file")
+		return nil, 0, err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		logger.WithFields(fields).Info("Could not open file")
+		return nil, 0, err
+	}
+	return f, stat.Size(), nil
+}
+
+// OpenWriterEnsureDirs creates the parent directories of path (see CreateDirectory), then opens path for writing
+// and returns the handle; an existing file is truncated
+// The caller is responsible for closing the handle
+// A mode of 0 uses the default file mode (see SetDefaultFileMode)
+func OpenWriterEnsureDirs(path string, mode os.FileMode) (io.WriteCloser, error) {
+	var err error
+	mode = fileMode(mode)
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return nil, err
+	}
+	var fields = logrus.Fields{
+		"file": logPath(path),
+		"mode": mode,
+	}
+
+	logger.WithFields(fields).Debug("Opening file for writing")
+	if err = CreateDirectory(filepath.Dir(path)); err != nil {
+		logger.WithFields(fields).Warn("Could not create parent directories")
+		return nil, err
+	}
+	f, err := osOpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		logger.WithFields(fields).Warn("Could not open file")
+		return nil, err
+	}
+	return f, nil
+}
+
+// PathDepth returns how many levels path is below root; root itself has a depth of 0
+// An *ErrNotDescendant is returned if path isn't root or inside it
+func PathDepth(root, path string) (int, error) {
+	var err error
+	root, err = BuildAbsolutePathFromHome(root)
+	if err != nil {
+		return 0, err
+	}
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return 0, err
+	}
+	var fields = logrus.Fields{
+		"root": logPath(root),
+		"path": logPath(path),
+	}
+
+	logger.WithFields(fields).Debug("Calculating path depth")
+	depth, err := pathDepth(root, path)
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to calculate path depth")
+		return 0, err
+	}
+	return depth, nil
+}
+
+// pathDepth returns how many levels path is below root without expanding either
+func pathDepth(root, path string) (int, error) {
+	relPath, err := filepath.Rel(filepath.Clean(root), filepath.Clean(path))
+	if err != nil {
+		return 0, err
+	}
+	if relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return 0, &ErrNotDescendant{Root: root, Path: path}
+	}
+	if relPath == "." {
+		return 0, nil
+	}
+	return strings.Count(relPath, string(filepath.Separator)) + 1, nil
+}
+
+// ReadFileFast loads the contents of path using a buffer sized from the file's current size, avoiding the
+// repeated growth of an unsized read
+// If the file shrinks or grows while being read, the contents actually read are returned
+func ReadFileFast(path string) ([]byte, error) {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return nil, err
+	}
+	var fields = logrus.Fields{
+		"file": logPath(path),
+	}
+
+	logger.WithFields(fields).Debug("Attempting to load file")
+	f, err := os.Open(path)
+	if err != nil {
+		logger.WithFields(fields).Info("Could not read file")
+		return []byte{}, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err == nil && stat.IsDir() {
+		err = errors.New(path + " is not a file")
+	}
+	if err != nil {
+		logger.WithFields(fields).Info("Could not read file")
+		return []byte{}, err
+	}
+
+	var contents = make([]byte, stat.Size())
+	n, err := io.ReadFull(f, contents)
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		// The file shrank after it was sized
+		contents, err = contents[:n], nil
+	} else if err == nil {
+		// Probe for anything appended after the file was sized before allocating for it
+		var probe [1]byte
+		if m, _ := f.Read(probe[:]); m > 0 {
+			var rest []byte
+			rest, err = ioutil.ReadAll(f)
+			contents = append(append(contents, probe[0]), rest...)
+		}
+	}
+	if err != nil {
+		logger.WithFields(fields).Info("Could not read file")
+		return []byte{}, err
+	}
+	logger.WithFields(fields).Debug("File read successfully")
+	return contents, nil
+}
+
+// ReadFileHeader reads up to the first n bytes of path without reading the rest of the file
+// Fewer than n bytes are returned if the file is shorter; only as much memory as is read is allocated
+func ReadFileHeader(path string, n int) ([]byte, error) {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return nil, err
+	}
+	var fields = logrus.Fields{
+		"file":  logPath(path),
+		"bytes": n,
+	}
+
+	logger.WithFields(fields).Debug("Reading file header")
+	if n < 0 {
+		return nil, errors.New("header size must not be negative")
+	}
+	if !isFile(path) {
+		err = errors.New(path + " is not a file")
+		logger.WithFields(fields).Info("Could not read file header")
+		return []byte{}, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		logger.WithFields(fields).Info("Could not read file header")
+		return []byte{}, err
+	}
+	defer f.Close()
+
+	// Grow the buffer as data arrives rather than trusting n or the reported size, so a large n on a small file
+	// costs nothing and files that report a size of 0 (i.e. under /proc) are still read
+	header, err := ioutil.ReadAll(io.LimitReader(f, int64(n)))
+	if err != nil {
+		logger.WithFields(fields).Info("Could not read file header")
+		return []byte{}, err
+	}
+	return header, nil
+}
+
+// ReadFileIfModifiedSince loads the contents of path only if it was modified after since
+// Returns the file's modification time and whether it changed; contents are only read (and returned) when changed
+func ReadFileIfModifiedSince(path string, since time.Time) ([]byte, time.Time, bool, error) {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return nil, time.Time{}, false, err
+	}
+	var fields = logrus.Fields{
+		"file":  logPath(path),
+		"since": since,
+	}
+
+	logger.WithFields(fields).Debug("Checking to see if file was modified")
+	if !isFile(path) {
+		err = errors.New(path + " is not a file")
+		logger.WithFields(fields).Info("Could not read file")
+		return nil, time.Time{}, false, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		logger.WithFields(fields).Info("Could not read file")
+		return nil, time.Time{}, false, err
+	}
+	defer f.Close()
+
+	// Stat the open handle so the modification time reported matches the contents read
+	stat, err := f.Stat()
+	if err != nil {
+		logger.WithFields(fields).Info("Could not read file")
+		return nil, time.Time{}, false, err
+	}
+	if !stat.ModTime().After(since) {
+		logger.WithFields(fields).Debug("File has not been modified")
+		return nil, stat.ModTime(), false, nil
+	}
+
+	contents, err := ioutil.ReadAll(f)
+	if err != nil {
+		logger.WithFields(fields).Info("Could not read file")
+		return nil, stat.ModTime(), false, err
+	}
+	logger.WithFields(fields).Debug("File read successfully")
+	return contents, stat.ModTime(), true, nil
+}
+
+// RemoveDirectory removes the directory at path from the system
+// If recursive is set to true, it will remove all children as well
+func RemoveDirectory(path string, recursive bool) error {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return err
+	}
+
+	var fields = logrus.Fields{
+		"directory": logPath(path),
+	}
+
+	logger.WithFields(fields).Debug("Attempting to remove directory")
+	if isDirectory(path) {
+		if recursive {
 			logger.WithFields(fields).Debug("Removing directory with recursion")
 			err = os.RemoveAll(path)
 		} else {
-			logger.WithFields(fields).Debug("Removing directory without recursion")
-			err = os.Remove(path)
+			logger.WithFields(fields).Debug("Removing directory without recursion")
+			err = os.Remove(path)
+		}
+		if err == nil {
+			logger.WithFields(fields).Debug("Directory was removed")
+			return nil
+		}
+	} else {
+		err = errors.New(path + " is not a directory")
+	}
+	logger.WithFields(fields).Warn("Failed to remove directory")
+	return err
+}
+
+// RemoveDirectoryReport recursively removes the directory at path and reports what was removed
+// The tree is walked first to count files (including symlinks and other non-directories) and directories
+// (including path itself) and to total the size of regular files; counts are only returned if removal succeeds
+func RemoveDirectoryReport(path string) (filesRemoved, dirsRemoved int, bytesFreed int64, err error) {
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	var fields = logrus.Fields{
+		"directory": logPath(path),
+	}
+
+	logger.WithFields(fields).Debug("Attempting to remove directory with report")
+	if !isDirectory(path) {
+		err = errors.New(path + " is not a directory")
+		logger.WithFields(fields).Warn("Failed to remove directory")
+		return 0, 0, 0, err
+	}
+	err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		switch {
+		case info.IsDir():
+			dirsRemoved++
+		case info.Mode().IsRegular():
+			filesRemoved++
+			bytesFreed += info.Size()
+		default:
+			filesRemoved++
+		}
+		return nil
+	})
+	if err == nil {
+		err = os.RemoveAll(path)
+	}
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to remove directory")
+		return 0, 0, 0, err
+	}
+	fields["files"] = filesRemoved
+	fields["directories"] = dirsRemoved
+	fields["bytes"] = bytesFreed
+	logger.WithFields(fields).Debug("Directory was removed")
+	return filesRemoved, dirsRemoved, bytesFreed, nil
+}
+
+// RemoveEmptyDirectory removes the directory at path only if it has no contents
+// An *ErrDirectoryNotEmpty is returned if the directory has contents
+func RemoveEmptyDirectory(path string) error {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return err
+	}
+	var fields = logrus.Fields{
+		"directory": logPath(path),
+	}
+
+	logger.WithFields(fields).Debug("Attempting to remove empty directory")
+	if !isDirectory(path) {
+		err = errors.New(path + " is not a directory")
+	} else if !IsEmptyDirectory(path) {
+		err = &ErrDirectoryNotEmpty{Path: path}
+	} else if err = os.Remove(path); err == nil {
+		logger.WithFields(fields).Debug("Directory was removed")
+		return nil
+	}
+	logger.WithFields(fields).Warn("Failed to remove directory")
+	return err
+}
+
+// reservedFilenames are device names Windows won't allow as a filename, with or without an extension
+var reservedFilenames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// SanitizeFilename turns a user-supplied name into a safe single-component filename
+// Path separators and characters Windows doesn't allow are replaced with _, control characters (including NUL)
+// are removed, leading dots and surrounding spaces are trimmed so the result can't be hidden or refer to a parent,
+// and reserved Windows device names (CON, PRN, COM1, etc.) are prefixed with _
+// An empty result is returned as _
+func SanitizeFilename(name string) string {
+	var sanitized = strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20 || r == 0x7f:
+			return -1
+		case strings.ContainsRune(`/\<>:"|?*`, r):
+			return '_'
+		}
+		return r
+	}, name)
+	sanitized = strings.TrimLeft(strings.TrimSpace(sanitized), ".")
+	// Windows silently drops trailing dots and spaces, which would make distinct names collide
+	sanitized = strings.TrimRight(sanitized, ". ")
+
+	var base = sanitized
+	if i := strings.Index(base, "."); i >= 0 {
+		base = base[:i]
+	}
+	if reservedFilenames[strings.ToUpper(strings.TrimSpace(base))] {
+		sanitized = "_" + sanitized
+	}
+	if sanitized == "" {
+		return "_"
+	}
+	return sanitized
+}
+
+// maxSymlinkHops is the number of symlinks SecureJoin follows before reporting a loop (matches Linux's limit)
+const maxSymlinkHops = 40
+
+// SecureJoin resolves unsafePath (i.e. from a URL) inside root and returns the resulting path, guaranteeing it
+// stays within root
+// Components are resolved one at a time like the kernel does, following symlinks (relative and absolute targets
+// must also stay within root) and applying .. to the resolved location; components that don't exist yet are
+// joined as given.  Both / and \ are treated as separators
+// An *ErrPathEscape is returned if unsafePath is absolute or anything resolves outside root, and an
+// *ErrSymlinkLoop if too many symlinks are followed.  Callers serving URL paths should trim the leading /
+// The returned path is based on root with its own symlinks resolved, and is only safe as long as nothing
+// in root is replaced with a symlink before it's used
+func SecureJoin(root, unsafePath string) (string, error) {
+	var err error
+	root, err = BuildAbsolutePathFromHome(root)
+	if err != nil {
+		return "", err
+	}
+	if root, err = filepath.Abs(root); err == nil {
+		root, err = filepath.EvalSymlinks(root)
+	}
+	if err != nil {
+		return "", err
+	}
+	var fields = logrus.Fields{
+		"root": logPath(root),
+		"path": logPath(unsafePath),
+	}
+
+	logger.WithFields(fields).Debug("Joining path under root")
+	var escape = func() (string, error) {
+		logger.WithFields(fields).Warn("Path escapes root")
+		return "", &ErrPathEscape{Root: root, Path: unsafePath}
+	}
+	var split = func(path string) []string {
+		return strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '\\' })
+	}
+	if filepath.IsAbs(unsafePath) || filepath.VolumeName(unsafePath) != "" || strings.HasPrefix(unsafePath, "/") || strings.HasPrefix(unsafePath, "\\") {
+		return escape()
+	}
+
+	var current = root
+	var components = split(unsafePath)
+	for hops := 0; len(components) > 0; {
+		var component = components[0]
+		components = components[1:]
+		switch component {
+		case ".":
+			continue
+		case "..":
+			if current == root {
+				return escape()
+			}
+			current = filepath.Dir(current)
+			continue
+		}
+
+		var next = filepath.Join(current, component)
+		info, err := os.Lstat(next)
+		if err != nil && !os.IsNotExist(err) {
+			logger.WithFields(fields).Warn("Failed to join path under root")
+			return "", err
+		}
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			current = next
+			continue
+		}
+
+		// Resolve the link by replacing it with its target's components
+		if hops++; hops > maxSymlinkHops {
+			logger.WithFields(fields).Warn("Failed to join path under root")
+			return "", &ErrSymlinkLoop{Path: next}
+		}
+		target, err := os.Readlink(next)
+		if err != nil {
+			logger.WithFields(fields).Warn("Failed to join path under root")
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			rel, err := filepath.Rel(root, filepath.Clean(target))
+			if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				return escape()
+			}
+			current, target = root, rel
+		}
+		components = append(split(target), components...)
+	}
+	fields["resolved"] = logPath(current)
+	logger.WithFields(fields).Debug("Joined path under root")
+	return current, nil
+}
+
+// SplitPath splits path into its components (i.e. /a/b/c.txt => ["a", "b", "c.txt"]) and reports whether it is absolute
+// ~ is expanded and the path is cleaned first, so trailing slashes and . components are dropped and .. components
+// are resolved; .. components that climb above the start of a relative path are kept (i.e. ../a => ["..", "a"])
+// The volume name on Windows is not included in the components; the root path / returns no components
+func SplitPath(path string) ([]string, bool, error) {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	path = filepath.Clean(path)
+	var absolute = filepath.IsAbs(path)
+	var separator = string(filepath.Separator)
+	var components = []string{}
+	for _, component := range strings.Split(path[len(filepath.VolumeName(path)):], separator) {
+		if component != "" && component != "." {
+			components = append(components, component)
+		}
+	}
+	return components, absolute, nil
+}
+
+// SwapFiles exchanges the files at a and b using three renames through a temp file next to a
+// Each rename is atomic, but the swap as a whole is not: for a moment b's contents are visible at a while b is
+// missing. If a rename fails, completed renames are rolled back. Both files must exist on the same filesystem
+func SwapFiles(a, b string) error {
+	var err error
+	a, err = BuildAbsolutePathFromHome(a)
+	if err != nil {
+		return err
+	}
+	b, err = BuildAbsolutePathFromHome(b)
+	if err != nil {
+		return err
+	}
+	var fields = logrus.Fields{
+		"first":  logPath(a),
+		"second": logPath(b),
+	}
+
+	logger.WithFields(fields).Debug("Swapping files")
+	for _, path := range []string{a, b} {
+		if !isFile(path) {
+			err = errors.New(path + " is not a file")
+			logger.WithFields(fields).Warn("Failed to swap files")
+			return err
+		}
+	}
+
+	// Reserve a unique name for a's contents while b takes its place
+	tmp, err := osTempFile(filepath.Dir(a), "."+filepath.Base(a)+".swap-")
+	if err == nil {
+		err = tmp.Close()
+		if err == nil {
+			err = os.Rename(a, tmp.Name())
+		}
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to swap files")
+		return err
+	}
+	if err = os.Rename(b, a); err != nil {
+		os.Rename(tmp.Name(), a)
+		logger.WithFields(fields).Warn("Failed to swap files")
+		return err
+	}
+	if err = os.Rename(tmp.Name(), b); err != nil {
+		os.Rename(a, b)
+		os.Rename(tmp.Name(), a)
+		logger.WithFields(fields).Warn("Failed to swap files")
+		return err
+	}
+	logger.WithFields(fields).Debug("Files swapped successfully")
+	return nil
+}
+
+// VerifyModes compares the modes of entries under root against expected, which is keyed by path relative to root
+// Returns the sorted relative paths whose permission bits (including setuid/setgid/sticky) differ from expected,
+// including expected paths that don't exist; entries under root that aren't in expected are ignored
+func VerifyModes(root string, expected map[string]os.FileMode) ([]string, error) {
+	var err error
+	root, err = BuildAbsolutePathFromHome(root)
+	if err != nil {
+		return nil, err
+	}
+	var fields = logrus.Fields{
+		"directory": logPath(root),
+	}
+
+	logger.WithFields(fields).Debug("Verifying modes")
+	if !isDirectory(root) {
+		err = errors.New(root + " is not a directory")
+		logger.WithFields(fields).Warn("Failed to verify modes")
+		return nil, err
+	}
+
+	const modeBits = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+	var mismatched = []string{}
+	for relPath, mode := range expected {
+		info, err := os.Lstat(filepath.Join(root, relPath))
+		if err != nil && !os.IsNotExist(err) {
+			logger.WithFields(fields).Warn("Failed to verify modes")
+			return nil, err
+		}
+		if err != nil || info.Mode()&modeBits != mode&modeBits {
+			mismatched = append(mismatched, relPath)
+		}
+	}
+	sort.Strings(mismatched)
+	fields["mismatched"] = len(mismatched)
+	logger.WithFields(fields).Debug("Modes verified")
+	return mismatched, nil
+}
+
+// WaitForFile blocks until path exists and is a file, checking every pollInterval
+// ErrTimeout is returned if the file doesn't appear before timeout elapses; errors other than path not existing
+// (i.e. permission errors) are returned immediately
+// Polling is used rather than fsnotify to keep the package free of that dependency, and because notifications
+// aren't delivered for files created by other machines on network mounts
+func WaitForFile(path string, timeout, pollInterval time.Duration) error {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return err
+	}
+	var fields = logrus.Fields{
+		"file":    logPath(path),
+		"timeout": timeout,
+	}
+	if pollInterval <= 0 {
+		pollInterval = 100 * time.Millisecond
+	}
+
+	logger.WithFields(fields).Debug("Waiting for file to appear")
+	var deadline = time.Now().Add(timeout)
+	for {
+		info, err := osStat(path)
+		if err == nil && !info.IsDir() {
+			break
+		}
+		if err != nil && !os.IsNotExist(err) {
+			logger.WithFields(fields).Warn("Failed to check for file")
+			return err
+		}
+		var remaining = time.Until(deadline)
+		if remaining <= 0 {
+			logger.WithFields(fields).Warn("Timed out waiting for file")
+			return ErrTimeout
+		}
+		if remaining < pollInterval {
+			time.Sleep(remaining)
+		} else {
+			time.Sleep(pollInterval)
 		}
+	}
+	logger.WithFields(fields).Debug("File found")
+	return nil
+}
+
+// WaitForStat stats path, retrying with backoff until it appears, for mounts (s3fs, gcsfuse, etc.) where a file
+// just written elsewhere may not be visible yet
+// Retries start at 10ms and double up to 500ms; ErrTimeout is returned if path doesn't appear before timeout
+// elapses, and errors other than the path not existing are returned immediately
+func WaitForStat(path string, timeout time.Duration) (*FileStat, error) {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return nil, err
+	}
+	var fields = logrus.Fields{
+		"path":    logPath(path),
+		"timeout": timeout,
+	}
+
+	logger.WithFields(fields).Debug("Waiting for path to appear")
+	var deadline = time.Now().Add(timeout)
+	for backoff := 10 * time.Millisecond; ; backoff *= 2 {
+		info, err := osStat(path)
 		if err == nil {
-			logger.WithFields(fields).Debug("Directory was removed")
-			return nil
+			logger.WithFields(fields).Debug("Path found")
+			return newFileStat(info), nil
 		}
-	} else {
-		err = errors.New(path + " is not a directory")
+		if !os.IsNotExist(err) {
+			logger.WithFields(fields).Warn("Failed to stat path")
+			return nil, err
+		}
+		var remaining = time.Until(deadline)
+		if remaining <= 0 {
+			logger.WithFields(fields).Warn("Timed out waiting for path")
+			return nil, ErrTimeout
+		}
+		if backoff > 500*time.Millisecond {
+			backoff = 500 * time.Millisecond
+		}
+		if backoff > remaining {
+			backoff = remaining
+		}
+		time.Sleep(backoff)
 	}
-	logger.WithFields(fields).Warn("Failed to remove directory")
-	return err
 }
 
 // WriteFile writes contents of data to path
+// If writing fails part way (i.e. the disk fills up), the partially written file is removed if path was a regular
+// file or didn't exist; symlinks, FIFOs, and device nodes are left in place
+// A mode of 0 uses the default file mode (see SetDefaultFileMode)
 func WriteFile(path string, data []byte, mode os.FileMode) error {
+	_, err := WriteFileN(path, data, mode)
+	return err
+}
+
+// WriteFileAtomic writes contents of data to path so readers see either the old or the new contents, never a partial write
+// data is written to a temp file in the same directory, synced, then renamed over path
+// If the directory isn't writable and a fallback temp directory is set (see SetTempDir), data is staged there and
+// then written into the existing file at path, which is not atomic: a failure part way can leave path partly
+// written.  A new file can't be created in an unwritable directory, so this only works if path already exists
+// Otherwise, on failure the temp file is removed and path is left untouched
+// A mode of 0 uses the default file mode (see SetDefaultFileMode)
+func WriteFileAtomic(path string, data []byte, mode os.FileMode) error {
+	return writeFileAtomic(path, data, mode, nil, true)
+}
+
+// writeFileAtomic writes data to path through a temp file, calling prepare (when provided) on the temp file
+// before its mode is set and it is renamed into place
+// The fallback temp directory is only used when allowFallback is set
+func writeFileAtomic(path string, data []byte, mode os.FileMode, prepare func(name string) error, allowFallback bool) error {
 	var err error
+	mode = fileMode(mode)
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return err
+	}
 	var fields = logrus.Fields{
-		"filename": path,
+		"filename": logPath(path),
 		"mode":     mode,
 	}
 
+	logger.WithFields(fields).Debug("Writing file atomically")
+	var pattern = "." + filepath.Base(path) + ".tmp-"
+	var staged = false
+	tmp, err := osTempFile(filepath.Dir(path), pattern)
+	if os.IsPermission(err) && allowFallback && fallbackTempDir != "" {
+		var dir string
+		if dir, err = BuildAbsolutePathFromHome(fallbackTempDir); err == nil {
+			fields["tempdir"] = logPath(dir)
+			logger.WithFields(fields).Warn("Directory is not writable; staging in fallback temp directory, write will not be atomic")
+			tmp, err = osTempFile(dir, pattern)
+			staged = true
+		}
+	}
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to write file")
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && prepare != nil {
+		err = prepare(tmp.Name())
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), mode)
+	}
+	if err == nil {
+		if staged {
+			err = writeStagedFile(tmp.Name(), path, mode)
+			os.Remove(tmp.Name())
+		} else {
+			err = os.Rename(tmp.Name(), path)
+		}
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		logger.WithFields(fields).Warn("Failed to write file")
+		return err
+	}
+	logger.WithFields(fields).Debug("Successfully wrote file")
+	return nil
+}
+
+// writeStagedFile overwrites the existing file at path with the contents of staged, then sets its mode
+// The directory containing path isn't writable, so path is rewritten in place rather than replaced
+func writeStagedFile(staged, path string, mode os.FileMode) error {
+	in, err := os.Open(staged)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if err == nil {
+		err = out.Sync()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Chmod(path, mode)
+}
+
+// WriteFileIfChanged writes contents of data to path unless path already holds identical contents
+// Skipping the write leaves the file (and its modification time) untouched; returns whether the file was written
+// A mode of 0 uses the default file mode (see SetDefaultFileMode)
+func WriteFileIfChanged(path string, data []byte, mode os.FileMode) (bool, error) {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return false, err
+	}
+	var fields = logrus.Fields{
+		"filename": logPath(path),
+	}
+
+	if isFile(path) {
+		var checksum = sha256.Sum256(data)
+		if existing, err := fileSHA256Checksum(path); err == nil && existing == hex.EncodeToString(checksum[:]) {
+			logger.WithFields(fields).Debug("File contents unchanged; skipping write")
+			return false, nil
+		}
+	}
+	if err = WriteFile(path, data, mode); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// WriteFileMode writes contents of data to path like WriteFile, applying the full mode including the setuid,
+// setgid, and sticky bits; these are set with an explicit chmod, since creating a file doesn't reliably apply
+// them (the umask and an existing file's mode both take precedence)
+// Setting setuid or setgid on an executable makes it run with the privileges of its owner or group, so only
+// use these bits for contents from a trusted source
+// A mode of 0 uses the default file mode (see SetDefaultFileMode)
+func WriteFileMode(path string, data []byte, mode os.FileMode) error {
+	var err error
+	mode = fileMode(mode)
 	path, err = BuildAbsolutePathFromHome(path)
 	if err != nil {
 		return err
 	}
+	var fields = logrus.Fields{
+		"filename": logPath(path),
+		"mode":     mode,
+	}
+
+	if err = WriteFile(path, data, mode); err != nil {
+		return err
+	}
+	var special = mode & (os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+	if special == 0 {
+		return nil
+	}
+	logger.WithFields(fields).Debug("Applying special mode bits")
+	if err = os.Chmod(path, mode&os.ModePerm|special); err != nil {
+		logger.WithFields(fields).Warn("Failed to apply special mode bits")
+		return err
+	}
+	return nil
+}
+
+// WriteFileN writes contents of data to path and returns the number of bytes written
+// If writing fails part way (i.e. the disk fills up), the partially written file is removed if path was a regular
+// file or didn't exist; symlinks, FIFOs, and device nodes are left in place
+// A mode of 0 uses the default file mode (see SetDefaultFileMode)
+func WriteFileN(path string, data []byte, mode os.FileMode) (int, error) {
+	var err error
+	mode = fileMode(mode)
+	var fields = logrus.Fields{
+		"filename": logPath(path),
+		"mode":     mode,
+	}
+
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return 0, err
+	}
 
 	logger.WithFields(fields).Debug("Writing file")
-	err = ioutil.WriteFile(path, data, mode)
+	// Only a regular file (or one this call creates) is removed on failure; removing a symlink, FIFO, or device
+	// node would unlink it without cleaning up whatever was actually written to
+	var n int
+	existing, err := os.Lstat(path)
+	var removable = os.IsNotExist(err) || (err == nil && existing.Mode().IsRegular())
+	f, err := osOpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err == nil {
+		n, err = f.Write(data)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil && removable {
+			// Don't leave truncated contents behind for callers to mistake for valid output
+			os.Remove(path)
+		}
+	}
+	fields["bytes"] = n
+	if err == nil {
+		logger.WithFields(fields).Debug("Successfully wrote file")
+	} else {
+		logger.WithFields(fields).Warn("Failed to write file")
+	}
+	return n, err
+}
+
+// WriteFileNoFollow writes contents of data to path, refusing to follow a symlink at path
+// An *ErrSymlinkDestination is returned if path is a symlink
+// A mode of 0 uses the default file mode (see SetDefaultFileMode)
+func WriteFileNoFollow(path string, data []byte, mode os.FileMode) error {
+	var err error
+	mode = fileMode(mode)
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return err
+	}
+	var fields = logrus.Fields{
+		"filename": logPath(path),
+		"mode":     mode,
+	}
+
+	logger.WithFields(fields).Debug("Writing file without following symlinks")
+	f, err := openNoFollow(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err == nil {
+		_, err = f.Write(data)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
 	if err == nil {
 		logger.WithFields(fields).Debug("Successfully wrote file")
 	} else {
@@ -352,3 +2335,139 @@ func WriteFile(path string, data []byte, mode os.FileMode) error {
 	}
 	return err
 }
+
+// WriteFileRotating writes contents of data to path, keeping up to keep previous versions as numbered backups
+// The existing file is copied to path.1, path.1 becomes path.2, and so on; numbered backups beyond keep are
+// removed, including those left by an earlier call with a larger keep (up to the first gap in the numbering)
+// path is then replaced atomically (see WriteFileAtomic), so a failed write leaves it in place
+// A mode of 0 uses the default file mode (see SetDefaultFileMode)
+func WriteFileRotating(path string, data []byte, mode os.FileMode, keep int) error {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return err
+	}
+	var fields = logrus.Fields{
+		"filename": logPath(path),
+		"keep":     keep,
+	}
+
+	logger.WithFields(fields).Debug("Rotating file backups")
+	if keep < 0 {
+		return errors.New("keep must not be negative")
+	}
+	var backup = func(n int) string {
+		return path + "." + strconv.Itoa(n)
+	}
+	err = removeBackupsFrom(path, keep+1)
+	if keep > 0 && err == nil && CheckExists(path) {
+		if err = os.Remove(backup(keep)); os.IsNotExist(err) {
+			err = nil
+		}
+		for n := keep - 1; n > 0 && err == nil; n-- {
+			if err = os.Rename(backup(n), backup(n+1)); os.IsNotExist(err) {
+				err = nil
+			}
+		}
+		if err == nil {
+			_, err = copyFile(path, backup(1))
+		}
+	}
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to rotate file backups")
+		return err
+	}
+	return WriteFileAtomic(path, data, mode)
+}
+
+// removeBackupsFrom removes the numbered backups of path starting at path.first, stopping at the first number
+// that doesn't exist, so unrelated files that happen to share the naming (i.e. app.log.2024) are left alone
+func removeBackupsFrom(path string, first int) error {
+	for n := first; ; n++ {
+		err := os.Remove(path + "." + strconv.Itoa(n))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// WriteFileOwned writes contents of data to path atomically (see WriteFileAtomic), owned by uid and gid
+// Ownership is set on the temp file before it is renamed into place, so path never exists with the wrong owner
+// Changing ownership generally requires root; without the needed privileges a permission error is returned
+// and path is left untouched
+// The fallback temp directory (see SetTempDir) is never used, since rewriting path in place would leave it with
+// its existing owner; an unwritable directory returns a permission error instead
+// A mode of 0 uses the default file mode (see SetDefaultFileMode)
+func WriteFileOwned(path string, data []byte, mode os.FileMode, uid, gid int) error {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return err
+	}
+	var fields = logrus.Fields{
+		"filename": logPath(path),
+		"uid":      uid,
+		"gid":      gid,
+	}
+
+	logger.WithFields(fields).Debug("Writing file with ownership")
+	return writeFileAtomic(path, data, mode, func(name string) error {
+		if err := os.Chown(name, uid, gid); err != nil {
+			logger.WithFields(fields).Warn("Failed to set file ownership")
+			if pathErr, ok := err.(*os.PathError); ok {
+				// Report the destination rather than the temp file that was being changed
+				return &os.PathError{Op: "chown", Path: path, Err: pathErr.Err}
+			}
+			return err
+		}
+		return nil
+	}, false)
+}
+
+// WriteFileUnique writes contents of data to path, picking a new name if path already exists
+// Existing files are never overwritten; a numbered suffix is added before the extension until
+// a free name is found (file.txt, file (1).txt, file (2).txt, ...)
+// Returns the path that was written
+func WriteFileUnique(path string, data []byte, mode os.FileMode) (string, error) {
+	var err error
+	mode = fileMode(mode)
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return "", err
+	}
+	var fields = logrus.Fields{
+		"filename": logPath(path),
+		"mode":     mode,
+	}
+
+	logger.WithFields(fields).Debug("Writing file with a unique name")
+	var actualPath = path
+	var f *os.File
+	for i := 1; ; i++ {
+		// O_EXCL guarantees the name is claimed atomically, even with concurrent writers
+		f, err = os.OpenFile(actualPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+		if !os.IsExist(err) {
+			break
+		}
+		actualPath = numberedPath(path, i)
+	}
+	if err == nil {
+		_, err = f.Write(data)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(actualPath)
+		}
+	}
+	fields["actual"] = logPath(actualPath)
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to write file")
+		return "", err
+	}
+	logger.WithFields(fields).Debug("Successfully wrote file")
+	return actualPath, nil
+}
@@ -0,0 +1,82 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package filesystem
+
+import (
+	"os"
+	"runtime"
+)
+
+// copyOwnership applies the ownership recorded in info to dst
+// Not supported on this platform; always returns ErrUnsupportedPlatform
+func copyOwnership(info os.FileInfo, dst string) error {
+	return ErrUnsupportedPlatform
+}
+
+// fileOwner returns the uid that owns the entry described by info
+// Not supported on this platform; always returns ErrUnsupportedPlatform
+func fileOwner(info os.FileInfo) (int, error) {
+	return 0, ErrUnsupportedPlatform
+}
+
+// GetInodeUsage returns the total and free inode counts for the filesystem containing path
+// Not supported on this platform; always returns ErrUnsupportedPlatform
+func GetInodeUsage(path string) (uint64, uint64, error) {
+	return 0, 0, ErrUnsupportedPlatform
+}
+
+// GetInode returns the inode number of path
+// Not supported on this platform; always returns ErrUnsupportedPlatform
+func GetInode(path string) (uint64, error) {
+	return 0, ErrUnsupportedPlatform
+}
+
+// GetLinkCount returns the number of hard links to path
+// Not supported on this platform; always returns ErrUnsupportedPlatform
+func GetLinkCount(path string) (uint64, error) {
+	return 0, ErrUnsupportedPlatform
+}
+
+// GetSparseSize returns the logical size of path and the number of bytes actually allocated for it on disk
+// Not supported on this platform; always returns ErrUnsupportedPlatform
+func GetSparseSize(path string) (int64, int64, error) {
+	return 0, 0, ErrUnsupportedPlatform
+}
+
+// HasSpaceFor reports whether the filesystem containing path has room for size more bytes
+// Not supported on this platform; always returns ErrUnsupportedPlatform
+func HasSpaceFor(path string, size int64) (bool, error) {
+	return false, ErrUnsupportedPlatform
+}
+
+// lockFile takes an exclusive advisory lock on f
+// Not supported on this platform; always returns ErrUnsupportedPlatform
+func lockFile(f *os.File) error {
+	return ErrUnsupportedPlatform
+}
+
+// openNoFollow opens path, refusing to do so if the final component is a symlink
+// O_NOFOLLOW isn't available on this platform, so the check is made before opening and is subject to races
+func openNoFollow(path string, flag int, mode os.FileMode) (*os.File, error) {
+	if stat, err := os.Lstat(path); err == nil && stat.Mode()&os.ModeSymlink != 0 {
+		return nil, &ErrSymlinkDestination{Path: path}
+	}
+	return os.OpenFile(path, flag, mode)
+}
+
+// pathLimits returns the longest path (including the terminating NUL) and longest path component the
+// platform accepts
+// Windows uses MAX_PATH, since long path support can't be assumed; other platforms use the common BSD limits
+func pathLimits() (int, int) {
+	if runtime.GOOS == "windows" {
+		return 260, 255
+	}
+	return 1024, 255
+}
+
+// unlockFile releases the advisory lock held on f
+// Not supported on this platform; always returns ErrUnsupportedPlatform
+func unlockFile(f *os.File) error {
+	return ErrUnsupportedPlatform
+}
@@ -1,12 +1,27 @@
 package filesystem
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
 	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
 	"reflect"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/fatih/color"
+	"github.com/mitchellh/go-homedir"
 	"github.com/sirupsen/logrus"
 )
 
@@ -269,6 +284,8 @@ func TestFileExtensionFunctionality(t *testing.T) {
 		"/full/path.txt":      "txt",
 		"~/relative/path.pdf": "pdf",
 		"test.":               "",
+		"/etc/cron.d/job":     "",
+		"~/a.b/c":             "",
 	}
 
 	var got string
@@ -278,4 +295,1727 @@ func TestFileExtensionFunctionality(t *testing.T) {
 			t.Error("Got back unexpected extension.", "Expected:", expected, "Got:", got)
 		}
 	}
+
+	var normalizedTestData = map[string]string{
+		"none":           "",
+		"file.ext":       "ext",
+		"IMG.JPG":        "jpg",
+		"Archive.TAR.GZ": "gz",
+		"/Full/Path.Txt": "txt",
+	}
+	for value, expected := range normalizedTestData {
+		got = GetFileExtensionNormalized(value)
+		if got != expected {
+			t.Error("Got back unexpected normalized extension.", "Expected:", expected, "Got:", got)
+		}
+	}
+	if GetFileExtension("IMG.JPG") != "JPG" {
+		t.Error("GetFileExtension should preserve case")
+	}
+}
+
+func TestGetCanonicalCase(t *testing.T) {
+	color.Yellow("Testing canonical case resolution")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	CreateDirectory(tempDir + "/Docs")
+	WriteFile(tempDir+"/Docs/Notes.txt", []byte("test"), 0644)
+
+	if got, err := GetCanonicalCase(tempDir + "/Docs/Notes.txt"); err != nil || got != tempDir+"/Docs/Notes.txt" {
+		t.Error("GetCanonicalCase changed an already canonical path:", "Got:", got, "Wanted:", tempDir+"/Docs/Notes.txt", err)
+	}
+	if _, err := GetCanonicalCase(tempDir + "/file-dne"); !os.IsNotExist(err) {
+		t.Error("GetCanonicalCase should fail for non-existent paths:", "Got:", err)
+	}
+
+	if !CheckExists(tempDir + "/docs") {
+		t.Skip("Filesystem is case-sensitive")
+	}
+	if got, err := GetCanonicalCase(tempDir + "/docs/notes.TXT"); err != nil || got != tempDir+"/Docs/Notes.txt" {
+		t.Error("GetCanonicalCase failed:", "Got:", got, "Wanted:", tempDir+"/Docs/Notes.txt", err)
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestGetDirectoryContentsAbsolute(t *testing.T) {
+	color.Yellow("Testing absolute directory listings")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	CreateDirectory(tempDir + "/sub")
+	WriteFile(tempDir+"/test.file", []byte("test"), 0644)
+
+	paths, err := GetDirectoryContentsAbsolute(tempDir + "/")
+	if err != nil || len(paths) != 2 {
+		t.Error("GetDirectoryContentsAbsolute returned unexpected entries:", paths, err)
+	}
+	for _, path := range paths {
+		if !filepath.IsAbs(path) || filepath.Dir(path) != tempDir {
+			t.Error("Entry is not a full path inside the directory:", path)
+		}
+		if !IsFile(path) && !IsDirectory(path) {
+			t.Error("Entry could not be used directly:", path)
+		}
+	}
+	if _, err := GetDirectoryContentsAbsolute(tempDir + "/dir-dne"); err == nil {
+		t.Error("GetDirectoryContentsAbsolute succeeded with non-existent directory")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestGetDirectoryContentsGrouped(t *testing.T) {
+	color.Yellow("Testing grouped directory listings")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	for _, dir := range []string{"zeta", "alpha", "Mid"} {
+		CreateDirectory(tempDir + "/" + dir)
+	}
+	for _, file := range []string{"b.txt", "a.txt", "zz.txt"} {
+		WriteFile(tempDir+"/"+file, []byte("test"), 0644)
+	}
+
+	dirs, files, err := GetDirectoryContentsGrouped(tempDir)
+	if expected := []string{"Mid", "alpha", "zeta"}; err != nil || !reflect.DeepEqual(dirs, expected) {
+		t.Error("Unexpected directories:", "Got:", dirs, "Wanted:", expected, err)
+	}
+	if expected := []string{"a.txt", "b.txt", "zz.txt"}; !reflect.DeepEqual(files, expected) {
+		t.Error("Unexpected files:", "Got:", files, "Wanted:", expected)
+	}
+	if _, _, err := GetDirectoryContentsGrouped(tempDir + "/dir-dne"); err == nil {
+		t.Error("GetDirectoryContentsGrouped succeeded with non-existent directory")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+// slowReader simulates a read that stalls (i.e. a FIFO without a writer)
+type slowReader struct {
+	delay time.Duration
+}
+
+func (r slowReader) Read(p []byte) (int, error) {
+	time.Sleep(r.delay)
+	return 0, io.EOF
+}
+
+func (r slowReader) Close() error {
+	return nil
+}
+
+func TestLoadFileBytesTimeout(t *testing.T) {
+	color.Yellow("Testing file reads with a timeout")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var testFile = tempDir + "/test.file"
+	WriteFile(testFile, []byte("test"), 0644)
+
+	if c, err := LoadFileBytesTimeout(testFile, time.Second); err != nil || string(c) != "test" {
+		t.Error("LoadFileBytesTimeout failed:", testFile, "Got:", string(c), "Wanted:", "test", err)
+	}
+	if _, err := LoadFileBytesTimeout(tempDir, time.Second); err == nil {
+		t.Error("LoadFileBytesTimeout succeeded with a directory")
+	}
+
+	var open = osOpen
+	defer func() {
+		osOpen = open
+	}()
+	osOpen = func(name string) (io.ReadCloser, error) {
+		return slowReader{time.Second}, nil
+	}
+	var start = time.Now()
+	if _, err := LoadFileBytesTimeout(testFile, 20*time.Millisecond); err != ErrTimeout {
+		t.Error("LoadFileBytesTimeout should time out on a stalled read:", "Got:", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Error("LoadFileBytesTimeout waited for the stalled read:", elapsed)
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestLoadFileOrReader(t *testing.T) {
+	color.Yellow("Testing loading from files or readers")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var testFile = tempDir + "/test.file"
+	WriteFile(testFile, []byte("from file"), 0644)
+
+	if c, err := LoadFileOrReader("-", strings.NewReader("from reader")); err != nil || string(c) != "from reader" {
+		t.Error("LoadFileOrReader did not read from fallback:", "Got:", string(c), "Wanted:", "from reader", err)
+	}
+	if c, err := LoadFileOrReader(testFile, strings.NewReader("from reader")); err != nil || string(c) != "from file" {
+		t.Error("LoadFileOrReader did not read from file:", "Got:", string(c), "Wanted:", "from file", err)
+	}
+	if _, err := LoadFileOrReader(tempDir+"/file-dne", strings.NewReader("from reader")); err == nil {
+		t.Error("LoadFileOrReader succeeded with non-existent file")
+	}
+	if _, err := LoadFileOrReader("-", nil); err == nil {
+		t.Error("LoadFileOrReader succeeded without a fallback reader")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestLoadFileOrEmpty(t *testing.T) {
+	color.Yellow("Testing loading optional files")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var testFile = tempDir + "/test.file"
+	if c, err := LoadFileOrEmpty(testFile); err != nil || c == nil || len(c) != 0 {
+		t.Error("LoadFileOrEmpty should treat a missing file as empty:", "Got:", c, err)
+	}
+	WriteFile(testFile, []byte("test"), 0644)
+	if c, err := LoadFileOrEmpty(testFile); err != nil || string(c) != "test" {
+		t.Error("File contents don't match what was saved:", "Got:", string(c), "Wanted:", "test", err)
+	}
+	if _, err := LoadFileOrEmpty(tempDir); err == nil {
+		t.Error("LoadFileOrEmpty succeeded with a directory")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestLoadFileVerified(t *testing.T) {
+	color.Yellow("Testing verified file loading")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var testFile = tempDir + "/test.file"
+	var checksum = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	WriteFile(testFile, []byte("test"), 0644)
+
+	if c, err := LoadFileVerified(testFile, checksum); err != nil || string(c) != "test" {
+		t.Error("LoadFileVerified failed with matching checksum:", testFile, err)
+	}
+
+	var badChecksum = strings.Repeat("0", 64)
+	c, err := LoadFileVerified(testFile, badChecksum)
+	if len(c) != 0 {
+		t.Error("LoadFileVerified returned contents on checksum mismatch")
+	}
+	if mismatch, ok := err.(*ErrChecksumMismatch); !ok {
+		t.Error("LoadFileVerified should return *ErrChecksumMismatch:", "Got:", err)
+	} else if mismatch.Expected != badChecksum || mismatch.Actual != checksum {
+		t.Error("Checksum mismatch error has incorrect digests:", mismatch)
+	}
+
+	if _, err := LoadFileVerified(tempDir+"/file-dne", checksum); err == nil {
+		t.Error("LoadFileVerified succeeded with non-existent file")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestRedactPaths(t *testing.T) {
+	color.Yellow("Testing log path redaction")
+	var output bytes.Buffer
+	var l = logrus.New()
+	l.Out = &output
+	l.Formatter = &logrus.JSONFormatter{}
+	l.Level = logrus.DebugLevel
+	SetLogger(l)
+	defer SetLogger(logrus.New())
+
+	home, err := homedir.Dir()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetRedactPaths(true)
+	IsFile("~/.filesystem-test-dne")
+	SetRedactPaths(false)
+
+	var entry map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Error("Could not parse log line:", line, err)
+			continue
+		}
+		if entry["path"] != "~/.filesystem-test-dne" {
+			t.Error("Log path field was not redacted:", "Got:", entry["path"], "Wanted:", "~/.filesystem-test-dne")
+		}
+	}
+
+	output.Reset()
+	IsFile("~/.filesystem-test-dne")
+	if !strings.Contains(output.String(), home) {
+		t.Error("Log path field should not be redacted when disabled:", output.String())
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestRemoveDirectoryReport(t *testing.T) {
+	color.Yellow("Testing directory removal reports")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var root = tempDir + "/tree"
+	CreateDirectory(root + "/a/b")
+	CreateDirectory(root + "/c")
+	WriteFile(root+"/one.file", []byte("test"), 0644)
+	WriteFile(root+"/a/two.file", []byte("longer test"), 0644)
+	WriteFile(root+"/a/b/three.file", []byte{}, 0644)
+
+	files, dirs, freed, err := RemoveDirectoryReport(root)
+	if err != nil {
+		t.Error("RemoveDirectoryReport failed:", root, err)
+	}
+	if files != 3 || dirs != 4 || freed != 15 {
+		t.Error("Unexpected removal report:", "Got:", files, dirs, freed, "Wanted:", 3, 4, 15)
+	}
+	if CheckExists(root) {
+		t.Error("Directory should have been removed but was found:", root)
+	}
+	if _, _, _, err := RemoveDirectoryReport(root); err == nil {
+		t.Error("RemoveDirectoryReport succeeded with non-existent directory")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestRemoveEmptyDirectory(t *testing.T) {
+	color.Yellow("Testing empty directory removal")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var empty = tempDir + "/empty"
+	CreateDirectory(empty)
+	if err := RemoveEmptyDirectory(empty); err != nil {
+		t.Error("RemoveEmptyDirectory failed on an empty directory:", empty, err)
+	}
+	if CheckExists(empty) {
+		t.Error("Directory should have been removed but was found:", empty)
+	}
+
+	var full = tempDir + "/full"
+	CreateDirectory(full)
+	WriteFile(full+"/test.file", []byte("test"), 0644)
+	err = RemoveEmptyDirectory(full)
+	if notEmpty, ok := err.(*ErrDirectoryNotEmpty); !ok {
+		t.Error("RemoveEmptyDirectory should return *ErrDirectoryNotEmpty:", "Got:", err)
+	} else if notEmpty.Path != full {
+		t.Error("ErrDirectoryNotEmpty has incorrect path:", "Got:", notEmpty.Path, "Wanted:", full)
+	}
+	if !IsDirectory(full) {
+		t.Error("Non-empty directory should not have been removed:", full)
+	}
+
+	if err := RemoveEmptyDirectory(tempDir + "/dne"); err == nil {
+		t.Error("RemoveEmptyDirectory succeeded with non-existent directory")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestOpenReaderAt(t *testing.T) {
+	color.Yellow("Testing random access reads")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var testFile = tempDir + "/test.file"
+	var testFileBytes = []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	WriteFile(testFile, testFileBytes, 0644)
+
+	r, size, err := OpenReaderAt(testFile)
+	if err != nil {
+		t.Fatal("OpenReaderAt failed:", testFile, err)
+	}
+	defer r.Close()
+	if size != int64(len(testFileBytes)) {
+		t.Error("OpenReaderAt returned incorrect size:", "Got:", size, "Wanted:", len(testFileBytes))
+	}
+
+	for _, section := range [][2]int64{{30, 6}, {0, 4}, {12, 10}, {35, 1}} {
+		var got = make([]byte, section[1])
+		if _, err := io.NewSectionReader(r, section[0], section[1]).Read(got); err != nil {
+			t.Error("Section read failed:", section, err)
+		}
+		var wanted = testFileBytes[section[0] : section[0]+section[1]]
+		if !reflect.DeepEqual(got, wanted) {
+			t.Error("Section contents don't match:", "Got:", string(got), "Wanted:", string(wanted))
+		}
+	}
+
+	if _, _, err := OpenReaderAt(tempDir); err == nil {
+		t.Error("OpenReaderAt succeeded on a directory")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestOpenWriterEnsureDirs(t *testing.T) {
+	color.Yellow("Testing writers to nested paths")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var testFile = tempDir + "/a/b/c/test.file"
+	w, err := OpenWriterEnsureDirs(testFile, 0644)
+	if err != nil {
+		t.Fatal("OpenWriterEnsureDirs failed:", testFile, err)
+	}
+	io.WriteString(w, "streamed ")
+	io.WriteString(w, "contents")
+	if err = w.Close(); err != nil {
+		t.Error("Closing the writer failed:", err)
+	}
+	if c, err := LoadFileString(testFile); err != nil || c != "streamed contents" {
+		t.Error("File has unexpected contents:", "Got:", c, err, "Wanted:", "streamed contents")
+	}
+
+	WriteFile(tempDir+"/blocker", []byte("test"), 0644)
+	if _, err := OpenWriterEnsureDirs(tempDir+"/blocker/test.file", 0644); err == nil {
+		t.Error("OpenWriterEnsureDirs succeeded below a file")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestWaitForFile(t *testing.T) {
+	color.Yellow("Testing waiting for files")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var testFile = tempDir + "/test.file"
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		WriteFile(testFile, []byte("test"), 0644)
+	}()
+	var start = time.Now()
+	if err := WaitForFile(testFile, 5*time.Second, 10*time.Millisecond); err != nil {
+		t.Error("WaitForFile failed:", testFile, err)
+	}
+	if time.Since(start) >= 5*time.Second {
+		t.Error("WaitForFile returned after the timeout")
+	}
+
+	if err := WaitForFile(tempDir+"/file-dne", 50*time.Millisecond, 10*time.Millisecond); err != ErrTimeout {
+		t.Error("WaitForFile should time out on a missing file:", "Got:", err)
+	}
+
+	// The wait doesn't sleep past its deadline
+	start = time.Now()
+	if err := WaitForFile(tempDir+"/file-dne", 50*time.Millisecond, 5*time.Second); err != ErrTimeout {
+		t.Error("WaitForFile should time out on a missing file:", "Got:", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Error("WaitForFile slept past its timeout:", elapsed)
+	}
+
+	// Errors other than the file not existing are returned immediately
+	var stat = osStat
+	defer func() {
+		osStat = stat
+	}()
+	osStat = func(name string) (os.FileInfo, error) {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrPermission}
+	}
+	start = time.Now()
+	if err := WaitForFile(testFile, 5*time.Second, 10*time.Millisecond); !os.IsPermission(err) {
+		t.Error("WaitForFile should return permission errors:", "Got:", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Error("WaitForFile kept waiting after a permission error:", elapsed)
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestWaitForStat(t *testing.T) {
+	color.Yellow("Testing waiting for stat")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var testFile = tempDir + "/test.file"
+	WriteFile(testFile, []byte("test"), 0644)
+
+	// Simulate a mount where the file only becomes visible after a few attempts
+	var stat = osStat
+	defer func() {
+		osStat = stat
+	}()
+	var attempts = 0
+	osStat = func(name string) (os.FileInfo, error) {
+		if attempts++; attempts <= 3 {
+			return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+		}
+		return stat(name)
+	}
+	info, err := WaitForStat(testFile, 5*time.Second)
+	if err != nil || info == nil || info.Name != "test.file" || info.Size != 4 {
+		t.Error("WaitForStat failed:", testFile, "Got:", info, err)
+	}
+	if attempts != 4 {
+		t.Error("Unexpected number of attempts:", "Got:", attempts, "Wanted:", 4)
+	}
+
+	osStat = stat
+	var start = time.Now()
+	if _, err := WaitForStat(tempDir+"/missing.file", 50*time.Millisecond); err != ErrTimeout {
+		t.Error("WaitForStat should time out for a missing file:", "Got:", err, "Wanted:", ErrTimeout)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Error("WaitForStat waited past its timeout:", elapsed)
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestConcatFiles(t *testing.T) {
+	color.Yellow("Testing file concatenation")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var paths = []string{tempDir + "/10-base.conf", tempDir + "/20-site.conf", tempDir + "/30-local.conf"}
+	WriteFile(paths[0], []byte("base = true"), 0644)
+	WriteFile(paths[1], []byte("site = example"), 0644)
+	WriteFile(paths[2], []byte("local = 1"), 0644)
+	var expected = "base = true\nsite = example\nlocal = 1"
+
+	if c, err := ConcatFiles(paths, []byte("\n")); err != nil || string(c) != expected {
+		t.Error("Concatenated contents don't match:", "Got:", string(c), err, "Wanted:", expected)
+	}
+	var buf bytes.Buffer
+	if n, err := ConcatFilesToWriter(&buf, paths, []byte("\n")); err != nil || buf.String() != expected || n != int64(len(expected)) {
+		t.Error("Streamed contents don't match:", "Got:", buf.String(), n, err, "Wanted:", expected)
+	}
+
+	buf.Reset()
+	if _, err := ConcatFilesToWriter(&buf, append(paths, tempDir+"/missing.conf"), []byte("\n")); err == nil {
+		t.Error("ConcatFilesToWriter succeeded with a missing file")
+	}
+	if buf.Len() != 0 {
+		t.Error("ConcatFilesToWriter wrote output before failing on a missing file:", buf.String())
+	}
+	if c, err := ConcatFiles(nil, []byte("\n")); err != nil || len(c) != 0 {
+		t.Error("Concatenating no files should return no contents:", "Got:", string(c), err)
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestCommonParent(t *testing.T) {
+	color.Yellow("Testing common parent detection")
+	home, err := homedir.Dir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var testData = []struct {
+		paths    []string
+		expected string
+	}{
+		{[]string{"/a/b/c/d.txt", "/a/b/c", "/a/b/c/e/f"}, "/a/b/c"},
+		{[]string{"/a/b/c.txt", "/a/b/d.txt"}, "/a/b"},
+		{[]string{"/a/bc", "/a/b"}, "/a"},
+		{[]string{"/a/b", "/c/d"}, "/"},
+		{[]string{"/a/b/"}, "/a/b"},
+		{[]string{"~/x/y", "~/x/z"}, home + "/x"},
+	}
+
+	for _, test := range testData {
+		got, err := CommonParent(test.paths)
+		if err != nil || got != test.expected {
+			t.Error("CommonParent failed:", test.paths, "Got:", got, "Wanted:", test.expected, err)
+		}
+	}
+	if _, err := CommonParent([]string{}); err == nil {
+		t.Error("CommonParent succeeded without paths")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestPathDepth(t *testing.T) {
+	color.Yellow("Testing path depth")
+	var testData = []struct {
+		root     string
+		path     string
+		expected int
+	}{
+		{"/a/b", "/a/b", 0},
+		{"/a/b/", "/a/b", 0},
+		{"/a/b", "/a/b/c", 1},
+		{"/a/b", "/a/b/c/d/e.txt", 3},
+		{"/", "/a/b", 2},
+		{"/a/b", "/a/b/../b/c", 1},
+	}
+
+	for _, test := range testData {
+		got, err := PathDepth(test.root, test.path)
+		if err != nil || got != test.expected {
+			t.Error("PathDepth failed:", test.root, test.path, "Got:", got, "Wanted:", test.expected, err)
+		}
+	}
+	for _, path := range []string{"/a", "/a/bc", "/c/d", "/a/b/.."} {
+		if _, err := PathDepth("/a/b", path); err == nil {
+			t.Error("PathDepth succeeded for non-descendant path:", path)
+		} else if _, ok := err.(*ErrNotDescendant); !ok {
+			t.Error("PathDepth should return *ErrNotDescendant:", path, "Got:", err)
+		}
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestIsCaseSensitive(t *testing.T) {
+	color.Yellow("Testing case sensitivity detection")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	sensitive, err := IsCaseSensitive(tempDir)
+	if err != nil {
+		t.Error("IsCaseSensitive failed:", tempDir, err)
+	}
+	if runtime.GOOS == "linux" && !sensitive {
+		t.Error("Linux temp directory reported as case-insensitive")
+	}
+	if contents, _ := GetDirectoryContents(tempDir); len(contents) != 0 {
+		t.Error("IsCaseSensitive left files behind:", contents)
+	}
+	if _, err := IsCaseSensitive(tempDir + "/dir-dne"); err == nil {
+		t.Error("IsCaseSensitive succeeded with non-existent directory")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestIsPathTooLong(t *testing.T) {
+	color.Yellow("Testing path length checks")
+	maxPath, maxName := pathLimits()
+	var deep = strings.Repeat("/directory", maxPath/len("/directory")+1)
+	var testData = map[string]bool{
+		"/tmp/file.txt":                          false,
+		"relative/path":                          false,
+		"/tmp/" + strings.Repeat("a", maxName):   false,
+		"/tmp/" + strings.Repeat("a", maxName+1): true,
+		deep:                                     true,
+		deep[:maxPath-1]:                         false,
+		strings.Repeat("a", maxName+1) + "/file.go": true,
+	}
+	for path, expected := range testData {
+		if got := IsPathTooLong(path); got != expected {
+			t.Error("Unexpected path length result:", len(path), "Got:", got, "Wanted:", expected)
+		}
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestIsSafeRelativePath(t *testing.T) {
+	color.Yellow("Testing relative path validation")
+	var testData = []struct {
+		path     string
+		expected bool
+	}{
+		{"a/b", true},
+		{"a/./b/", true},
+		{"file..txt", true},
+		{"../x", false},
+		{"/abs", false},
+		{"a/../../b", false},
+		{"a/../b", false},
+		{"a\\..\\..\\b", false},
+		{"\\abs", false},
+		{".", false},
+		{"", false},
+	}
+
+	for _, test := range testData {
+		if got := IsSafeRelativePath(test.path); got != test.expected {
+			t.Error("IsSafeRelativePath failed:", test.path, "Got:", got, "Wanted:", test.expected)
+		}
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	color.Yellow("Testing filename sanitization")
+	var testData = []struct {
+		name     string
+		expected string
+	}{
+		{"report.pdf", "report.pdf"},
+		{"../../etc/passwd", "_.._etc_passwd"},
+		{"..\\windows\\system.ini", "_windows_system.ini"},
+		{".hidden", "hidden"},
+		{"con.txt", "_con.txt"},
+		{"CON", "_CON"},
+		{"lpt1.tar.gz", "_lpt1.tar.gz"},
+		{"console.txt", "console.txt"},
+		{"bad\x00na\tme\n.txt", "badname.txt"},
+		{"what?.txt", "what_.txt"},
+		{"trailing. ", "trailing"},
+		{"..", "_"},
+		{"", "_"},
+	}
+
+	for _, test := range testData {
+		got := SanitizeFilename(test.name)
+		if got != test.expected {
+			t.Errorf("SanitizeFilename failed: %q Got: %q Wanted: %q", test.name, got, test.expected)
+		}
+		if strings.ContainsAny(got, "/\\\x00") {
+			t.Errorf("SanitizeFilename returned an unsafe name: %q", got)
+		}
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestSecureJoin(t *testing.T) {
+	color.Yellow("Testing secure path joins")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var root = tempDir + "/root"
+	CreateDirectory(root + "/docs")
+	WriteFile(root+"/docs/index.html", []byte("test"), 0644)
+	root, _ = filepath.EvalSymlinks(root)
+
+	var testData = map[string]string{
+		"docs/index.html":        root + "/docs/index.html",
+		"docs/../docs/./a.html":  root + "/docs/a.html",
+		"docs//missing/../b.css": root + "/docs/b.css",
+		"new/dir/file.txt":       root + "/new/dir/file.txt",
+		"":                       root,
+	}
+	for path, expected := range testData {
+		if got, err := SecureJoin(root, path); err != nil || got != expected {
+			t.Error("Unexpected join:", path, "Got:", got, err, "Wanted:", expected)
+		}
+	}
+	for _, path := range []string{"../../etc/passwd", "docs/../../root", "/etc/passwd", "\\etc\\passwd", "docs\\..\\.."} {
+		_, err := SecureJoin(root, path)
+		if _, ok := err.(*ErrPathEscape); !ok {
+			t.Error("Escaping path was not rejected:", path, "Got:", err)
+		}
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestSplitPath(t *testing.T) {
+	color.Yellow("Testing path splitting")
+	home, err := homedir.Dir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	homeComponents, _, _ := SplitPath(home)
+	var testData = []struct {
+		path       string
+		components []string
+		absolute   bool
+	}{
+		{"/a/b/c.txt", []string{"a", "b", "c.txt"}, true},
+		{"/a/b/", []string{"a", "b"}, true},
+		{"/", []string{}, true},
+		{"/a/./b/../c", []string{"a", "c"}, true},
+		{"/..", []string{}, true},
+		{"a/b", []string{"a", "b"}, false},
+		{"./a/b/", []string{"a", "b"}, false},
+		{"../a", []string{"..", "a"}, false},
+		{"a/../..", []string{".."}, false},
+		{".", []string{}, false},
+		{"", []string{}, false},
+		{"~/x/y", append(homeComponents, "x", "y"), true},
+	}
+
+	for _, test := range testData {
+		components, absolute, err := SplitPath(test.path)
+		if err != nil || absolute != test.absolute || !reflect.DeepEqual(components, test.components) {
+			t.Error("SplitPath failed:", test.path, "Got:", components, absolute, "Wanted:", test.components, test.absolute, err)
+		}
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestSwapFiles(t *testing.T) {
+	color.Yellow("Testing file swaps")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var blue = tempDir + "/blue.conf"
+	var green = tempDir + "/green.conf"
+	WriteFile(blue, []byte("blue"), 0644)
+	WriteFile(green, []byte("green"), 0600)
+
+	if err := SwapFiles(blue, green); err != nil {
+		t.Error("SwapFiles failed:", blue, green, err)
+	}
+	if c, err := LoadFileString(blue); err != nil || c != "green" {
+		t.Error("Swapped file contents don't match:", blue, "Got:", c, "Wanted:", "green")
+	}
+	if c, err := LoadFileString(green); err != nil || c != "blue" {
+		t.Error("Swapped file contents don't match:", green, "Got:", c, "Wanted:", "blue")
+	}
+	if c, err := GetDirectoryContents(tempDir); err != nil || len(c) != 2 {
+		t.Error("SwapFiles left temp files behind:", c)
+	}
+
+	if err := SwapFiles(blue, tempDir+"/file-dne"); err == nil {
+		t.Error("SwapFiles succeeded with non-existent file")
+	}
+	if c, _ := LoadFileString(blue); c != "green" {
+		t.Error("A failed swap changed the existing file:", "Got:", c, "Wanted:", "green")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestContextChecks(t *testing.T) {
+	color.Yellow("Testing context-aware checks")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var testFile = tempDir + "/test.file"
+	WriteFile(testFile, []byte("test"), 0644)
+	if exists, err := CheckExistsContext(context.Background(), testFile); err != nil || !exists {
+		t.Error("CheckExistsContext failed:", testFile, err)
+	}
+	if exists, err := CheckExistsContext(context.Background(), tempDir+"/file-dne"); err != nil || exists {
+		t.Error("CheckExistsContext found a non-existent file:", err)
+	}
+	if isFile, err := IsFileContext(context.Background(), testFile); err != nil || !isFile {
+		t.Error("IsFileContext failed:", testFile, err)
+	}
+	if isFile, err := IsFileContext(context.Background(), tempDir); err != nil || isFile {
+		t.Error("IsFileContext reported a directory as a file:", tempDir, err)
+	}
+
+	// Simulate a hung mount
+	var unblock = make(chan struct{})
+	osStat = func(name string) (os.FileInfo, error) {
+		<-unblock
+		return os.Stat(name)
+	}
+	defer func() {
+		close(unblock)
+		osStat = os.Stat
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var start = time.Now()
+	if _, err := CheckExistsContext(ctx, testFile); err != context.DeadlineExceeded {
+		t.Error("CheckExistsContext should respect the deadline:", "Got:", err)
+	}
+	if _, err := IsFileContext(ctx, testFile); err != context.DeadlineExceeded {
+		t.Error("IsFileContext should respect the deadline:", "Got:", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Context checks blocked past the deadline")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestCreateTree(t *testing.T) {
+	color.Yellow("Testing directory tree creation")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var root = tempDir + "/project"
+	var files = map[string][]byte{
+		"README.md":          []byte("readme"),
+		"src/main.go":        []byte("package main"),
+		"src/lib/helpers.go": []byte("package lib"),
+		"config/app.yaml":    []byte("key: value"),
+	}
+	var dirs = []string{"build", "src/lib/empty"}
+	if err = CreateTree(root, files, dirs); err != nil {
+		t.Error("CreateTree failed:", err)
+	}
+	for name, data := range files {
+		if contents, err := LoadFileBytes(root + "/" + name); err != nil || string(contents) != string(data) {
+			t.Error("File has unexpected contents:", name, "Got:", string(contents), err, "Wanted:", string(data))
+		}
+	}
+	for _, dir := range dirs {
+		if !IsDirectory(root + "/" + dir) {
+			t.Error("Directory was not created:", dir)
+		}
+	}
+
+	// Nothing is left behind on failure
+	var failing = map[string][]byte{
+		"a/b.txt":   []byte("file"),
+		"a/b.txt/c": []byte("file below a file"),
+		"d.txt":     []byte("file"),
+	}
+	if err = CreateTree(tempDir+"/failed/tree", failing, []string{"e"}); err == nil {
+		t.Error("CreateTree succeeded with conflicting paths")
+	}
+	if CheckExists(tempDir + "/failed") {
+		t.Error("Failed CreateTree left files behind")
+	}
+
+	if err = CreateTree(tempDir+"/unsafe", map[string][]byte{"../escape": nil}, nil); err == nil {
+		t.Error("CreateTree accepted a path outside root")
+	}
+	if CheckExists(tempDir+"/unsafe") || CheckExists(tempDir+"/escape") {
+		t.Error("CreateTree created files for an unsafe path")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestIterateDirectory(t *testing.T) {
+	color.Yellow("Testing directory iteration")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var count = 1000
+	for i := 0; i < count; i++ {
+		WriteFile(tempDir+"/"+strconv.Itoa(i)+".file", []byte{}, 0644)
+	}
+
+	var visited = map[string]int{}
+	if err := IterateDirectory(tempDir, func(info os.FileInfo) error {
+		visited[info.Name()]++
+		return nil
+	}); err != nil {
+		t.Error("IterateDirectory failed:", tempDir, err)
+	}
+	if len(visited) != count {
+		t.Error("IterateDirectory visited wrong number of entries:", "Got:", len(visited), "Wanted:", count)
+	}
+	for name, n := range visited {
+		if n != 1 {
+			t.Error("Entry visited more than once:", name, n)
+		}
+	}
+
+	var stop = errors.New("stop")
+	var calls = 0
+	if err := IterateDirectory(tempDir, func(info os.FileInfo) error {
+		calls++
+		if calls == 10 {
+			return stop
+		}
+		return nil
+	}); err != stop || calls != 10 {
+		t.Error("IterateDirectory should stop on callback error:", "Calls:", calls, err)
+	}
+
+	if err := IterateDirectory(tempDir+"/dne", func(os.FileInfo) error { return nil }); err == nil {
+		t.Error("IterateDirectory succeeded with non-existent directory")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestExpandUserPath(t *testing.T) {
+	color.Yellow("Testing ~user expansion")
+	home, err := homedir.Dir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ExpandUserPath("~/x"); err != nil || got != home+"/x" {
+		t.Error("ExpandUserPath failed for current user:", "Got:", got, "Wanted:", home+"/x", err)
+	}
+	if got, err := ExpandUserPath("/already/absolute"); err != nil || got != "/already/absolute" {
+		t.Error("ExpandUserPath modified an absolute path:", "Got:", got, err)
+	}
+
+	if root, err := user.Lookup("root"); err == nil {
+		if got, err := ExpandUserPath("~root/x"); err != nil || got != root.HomeDir+"/x" {
+			t.Error("ExpandUserPath failed for root:", "Got:", got, "Wanted:", root.HomeDir+"/x", err)
+		}
+	}
+
+	_, err = ExpandUserPath("~filesystem-test-no-such-user/x")
+	if _, ok := err.(user.UnknownUserError); !ok {
+		t.Error("ExpandUserPath should return user.UnknownUserError for unknown users:", "Got:", err)
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestFilterDirectory(t *testing.T) {
+	color.Yellow("Testing directory filtering")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var old = time.Now().Add(-72 * time.Hour)
+	for _, name := range []string{"old-1", "old-2", "new-1"} {
+		WriteFile(tempDir+"/"+name, []byte("test"), 0644)
+	}
+	os.Chtimes(tempDir+"/old-1", old, old)
+	os.Chtimes(tempDir+"/old-2", old, old)
+
+	var cutoff = time.Now().Add(-24 * time.Hour)
+	recent, err := FilterDirectory(tempDir, func(info os.FileInfo) bool {
+		return info.ModTime().After(cutoff)
+	})
+	if err != nil {
+		t.Error("FilterDirectory failed:", tempDir, err)
+	}
+	if len(recent) != 1 || recent[0].Name() != "new-1" {
+		t.Error("FilterDirectory returned incorrect entries:", recent)
+	}
+
+	if _, err := FilterDirectory(tempDir+"/dne", func(os.FileInfo) bool { return true }); err == nil {
+		t.Error("FilterDirectory succeeded with non-existent directory")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestWriteFileN(t *testing.T) {
+	color.Yellow("Testing byte counts from writes")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	for _, size := range []int{0, 1, 4096, 100000} {
+		var data = bytes.Repeat([]byte("x"), size)
+		var testFile = tempDir + "/" + strconv.Itoa(size) + ".file"
+		if n, err := WriteFileN(testFile, data, 0644); err != nil || n != size {
+			t.Error("WriteFileN returned incorrect count:", "Got:", n, "Wanted:", size, err)
+		}
+		if c, err := LoadFileBytes(testFile); err != nil || len(c) != size {
+			t.Error("Written file has incorrect size:", "Got:", len(c), "Wanted:", size, err)
+		}
+	}
+
+	if n, err := WriteFileN(tempDir+"/dne/expect-error", []byte("test"), 0644); err == nil || n != 0 {
+		t.Error("WriteFileN should have failed:", n, err)
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestWriteFileRotating(t *testing.T) {
+	color.Yellow("Testing rotating writes")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var testFile = tempDir + "/audit.log"
+	for _, generation := range []string{"1", "2", "3", "4", "5"} {
+		if err := WriteFileRotating(testFile, []byte(generation), 0644, 2); err != nil {
+			t.Error("WriteFileRotating failed:", testFile, generation, err)
+		}
+	}
+
+	var expected = map[string]string{
+		testFile:        "5",
+		testFile + ".1": "4",
+		testFile + ".2": "3",
+	}
+	for path, contents := range expected {
+		if c, err := LoadFileString(path); err != nil || c != contents {
+			t.Error("Rotated file contents don't match:", path, "Got:", c, "Wanted:", contents)
+		}
+	}
+	if CheckExists(testFile + ".3") {
+		t.Error("WriteFileRotating kept more backups than requested")
+	}
+	if contents, _ := GetDirectoryContents(tempDir); len(contents) != 3 {
+		t.Error("Unexpected files after rotation:", contents)
+	}
+
+	// Shrinking keep removes every backup beyond it, but not unrelated files with numeric suffixes
+	var unrelated = testFile + ".2024"
+	WriteFile(unrelated, []byte("2024"), 0644)
+	WriteFileRotating(testFile, []byte("6"), 0644, 4)
+	WriteFileRotating(testFile, []byte("7"), 0644, 4)
+	if err := WriteFileRotating(testFile, []byte("8"), 0644, 1); err != nil {
+		t.Error("WriteFileRotating failed:", testFile, err)
+	}
+	if contents, _ := GetDirectoryContents(tempDir); len(contents) != 3 || CheckExists(testFile+".2") {
+		t.Error("WriteFileRotating left backups beyond keep:", contents)
+	}
+	if c, err := LoadFileString(unrelated); err != nil || c != "2024" {
+		t.Error("WriteFileRotating removed an unrelated file:", unrelated, err)
+	}
+	if c, _ := LoadFileString(testFile + ".1"); c != "7" {
+		t.Error("Rotated file contents don't match:", "Got:", c, "Wanted:", "7")
+	}
+
+	// A failed write leaves path in place
+	var tempFile = osTempFile
+	defer func() {
+		osTempFile = tempFile
+	}()
+	osTempFile = func(dir, pattern string) (writableFile, error) {
+		return nil, errors.New("simulated failure")
+	}
+	if err := WriteFileRotating(testFile, []byte("9"), 0644, 1); err == nil {
+		t.Error("WriteFileRotating succeeded when the write failed")
+	}
+	osTempFile = tempFile
+	if c, err := LoadFileString(testFile); err != nil || c != "8" {
+		t.Error("WriteFileRotating lost the file when the write failed:", "Got:", c, "Wanted:", "8", err)
+	}
+
+	if err := WriteFileRotating(testFile, []byte("6"), 0644, -1); err == nil {
+		t.Error("WriteFileRotating succeeded with a negative keep")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestWriteFileUnique(t *testing.T) {
+	color.Yellow("Testing collision-safe writes")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var wanted = []string{tempDir + "/file.txt", tempDir + "/file (1).txt", tempDir + "/file (2).txt"}
+	for i, expected := range wanted {
+		var contents = strconv.Itoa(i)
+		got, err := WriteFileUnique(tempDir+"/file.txt", []byte(contents), 0644)
+		if err != nil || got != expected {
+			t.Error("WriteFileUnique used an unexpected path:", "Got:", got, "Wanted:", expected, err)
+		}
+		if c, err := LoadFileString(expected); err != nil || c != contents {
+			t.Error("File contents don't match what was saved:", expected, "Got:", c, "Wanted:", contents)
+		}
+	}
+	if c, err := GetDirectoryContents(tempDir); err != nil || len(c) != 3 {
+		t.Error("WriteFileUnique should have created three files:", c)
+	}
+
+	if got, err := WriteFileUnique(tempDir+"/no-extension", []byte("test"), 0644); err != nil || got != tempDir+"/no-extension" {
+		t.Error("WriteFileUnique failed:", got, err)
+	}
+	if got, err := WriteFileUnique(tempDir+"/no-extension", []byte("test"), 0644); err != nil || got != tempDir+"/no-extension (1)" {
+		t.Error("WriteFileUnique used an unexpected path:", "Got:", got, "Wanted:", tempDir+"/no-extension (1)", err)
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestFlush(t *testing.T) {
+	color.Yellow("Testing log flushing")
+	var output bytes.Buffer
+	var buffered = bufio.NewWriter(&output)
+	var l = logrus.New()
+	l.Out = buffered
+	l.Level = logrus.DebugLevel
+	SetLogger(l)
+	defer SetLogger(logrus.New())
+
+	IsFile("/tmp/.filesystem-test-dne")
+	if output.Len() != 0 {
+		t.Error("Log output should still be buffered before Flush")
+	}
+	if err := Flush(); err != nil {
+		t.Error("Flush failed:", err)
+	}
+	if !strings.Contains(output.String(), "Checking to see if path is a file") {
+		t.Error("Log output was not flushed:", output.String())
+	}
+
+	SetLogger(logrus.New())
+	if err := Flush(); err != nil {
+		t.Error("Flush should be a no-op for unbuffered output:", err)
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestMoveFileIntoDirectory(t *testing.T) {
+	color.Yellow("Testing moving files into directories")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var src = tempDir + "/report.txt"
+	var destDir = tempDir + "/archive/2024"
+	WriteFile(src, []byte("first"), 0644)
+	target, err := MoveFileIntoDirectory(src, destDir, false)
+	if err != nil || target != destDir+"/report.txt" {
+		t.Error("MoveFileIntoDirectory failed:", "Got:", target, "Wanted:", destDir+"/report.txt", err)
+	}
+	if c, err := LoadFileString(destDir + "/report.txt"); err != nil || c != "first" {
+		t.Error("Moved file contents don't match:", "Got:", c, "Wanted:", "first")
+	}
+	if CheckExists(src) {
+		t.Error("Source file still exists after move:", src)
+	}
+
+	WriteFile(src, []byte("second"), 0644)
+	if _, err := MoveFileIntoDirectory(src, destDir, false); !os.IsExist(err) {
+		t.Error("MoveFileIntoDirectory should refuse to overwrite:", "Got:", err)
+	}
+	if c, _ := LoadFileString(destDir + "/report.txt"); c != "first" || !CheckExists(src) {
+		t.Error("A refused move changed the files")
+	}
+	if _, err := MoveFileIntoDirectory(src, destDir, true); err != nil {
+		t.Error("MoveFileIntoDirectory failed to overwrite:", err)
+	}
+	if c, _ := LoadFileString(destDir + "/report.txt"); c != "second" {
+		t.Error("Overwritten file contents don't match:", "Got:", c, "Wanted:", "second")
+	}
+	if _, err := MoveFileIntoDirectory(tempDir+"/file-dne", destDir, true); err == nil {
+		t.Error("MoveFileIntoDirectory succeeded with non-existent file")
+	}
+
+	// Only one of several concurrent moves to the same name succeeds without overwrite
+	const movers = 20
+	var moved = make(chan int, movers)
+	var wg sync.WaitGroup
+	for i := 0; i < movers; i++ {
+		var dir = tempDir + "/concurrent/" + strconv.Itoa(i)
+		CreateDirectory(dir)
+		WriteFile(dir+"/data.txt", []byte(strconv.Itoa(i)), 0644)
+		wg.Add(1)
+		go func(i int, src string) {
+			defer wg.Done()
+			_, err := MoveFileIntoDirectory(src, tempDir+"/collected", false)
+			if err == nil {
+				moved <- i
+			} else if !os.IsExist(err) {
+				t.Error("MoveFileIntoDirectory failed:", src, err)
+			}
+		}(i, dir+"/data.txt")
+	}
+	wg.Wait()
+	close(moved)
+	if len(moved) != 1 {
+		t.Error("Unexpected number of successful moves:", "Got:", len(moved), "Wanted:", 1)
+	} else if c, _ := LoadFileString(tempDir + "/collected/data.txt"); c != strconv.Itoa(<-moved) {
+		t.Error("Moved file was overwritten by a concurrent move:", "Got:", c)
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestNormalizePaths(t *testing.T) {
+	color.Yellow("Testing path normalization")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	home, err := homedir.Dir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	workingDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(workingDir)
+	os.Chdir(tempDir)
+
+	var file = tempDir + "/a"
+	WriteFile(file, []byte("test"), 0644)
+	os.Symlink(file, tempDir+"/link")
+	fromHome, err := filepath.Rel(home, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	canonical, err := filepath.EvalSymlinks(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var paths = []string{"~/" + fromHome, "./a", tempDir + "/link", tempDir + "/sub/../a", tempDir + "/dne/", "b"}
+	var expected = []string{canonical, tempDir + "/dne", tempDir + "/b"}
+	if got, err := NormalizePaths(paths); err != nil || !reflect.DeepEqual(got, expected) {
+		t.Error("NormalizePaths failed:", "Got:", got, "Wanted:", expected, err)
+	}
+	if got, err := NormalizePaths([]string{}); err != nil || len(got) != 0 {
+		t.Error("NormalizePaths failed with no paths:", got, err)
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestNewestAndOldestFile(t *testing.T) {
+	color.Yellow("Testing newest and oldest file selection")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	CreateDirectory(tempDir + "/subdirectory")
+	if _, _, err := NewestFile(tempDir); err == nil {
+		t.Error("NewestFile succeeded in a directory without files")
+	} else if _, ok := err.(*ErrEmptyDirectory); !ok {
+		t.Error("NewestFile should return *ErrEmptyDirectory:", "Got:", err)
+	}
+	if _, _, err := OldestFile(tempDir); err == nil {
+		t.Error("OldestFile succeeded in a directory without files")
+	}
+
+	var now = time.Now()
+	var modTimes = map[string]time.Time{
+		"middle": now.Add(-time.Hour),
+		"oldest": now.Add(-2 * time.Hour),
+		"newest": now,
+	}
+	for name, modTime := range modTimes {
+		WriteFile(tempDir+"/"+name, []byte("test"), 0644)
+		os.Chtimes(tempDir+"/"+name, modTime, modTime)
+	}
+	// The subdirectory is newer than every file and must be ignored
+	os.Chtimes(tempDir+"/subdirectory", now.Add(time.Hour), now.Add(time.Hour))
+
+	if path, info, err := NewestFile(tempDir); err != nil || path != tempDir+"/newest" || info.Name() != "newest" {
+		t.Error("NewestFile selected the wrong file:", "Got:", path, err)
+	}
+	if path, info, err := OldestFile(tempDir); err != nil || path != tempDir+"/oldest" || info.Name() != "oldest" {
+		t.Error("OldestFile selected the wrong file:", "Got:", path, err)
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestReadFileFast(t *testing.T) {
+	color.Yellow("Testing presized file reads")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var testFile = tempDir + "/test.file"
+	for _, size := range []int{0, 1, 4095, 4096, 4097, 1 << 20} {
+		var data = bytes.Repeat([]byte{'x'}, size)
+		WriteFile(testFile, data, 0644)
+		if c, err := ReadFileFast(testFile); err != nil || !bytes.Equal(c, data) {
+			t.Error("ReadFileFast returned unexpected contents:", "Size:", size, "Got:", len(c), err)
+		}
+	}
+	if _, err := ReadFileFast(tempDir); err == nil {
+		t.Error("ReadFileFast succeeded with a directory")
+	}
+	if _, err := ReadFileFast(tempDir + "/file-dne"); err == nil {
+		t.Error("ReadFileFast succeeded with non-existent file")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestReadFileHeader(t *testing.T) {
+	color.Yellow("Testing file header reads")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var pngMagic = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'}
+	var image = tempDir + "/image.png"
+	WriteFile(image, append(append([]byte{}, pngMagic...), bytes.Repeat([]byte{0}, 4096)...), 0644)
+	if header, err := ReadFileHeader(image, len(pngMagic)); err != nil || !bytes.Equal(header, pngMagic) {
+		t.Error("ReadFileHeader returned unexpected bytes:", "Got:", header, "Wanted:", pngMagic, err)
+	}
+
+	var short = tempDir + "/short.file"
+	WriteFile(short, []byte("abc"), 0644)
+	if header, err := ReadFileHeader(short, 8); err != nil || string(header) != "abc" {
+		t.Error("ReadFileHeader failed on a short file:", "Got:", string(header), "Wanted:", "abc", err)
+	}
+	if header, err := ReadFileHeader(short, int(^uint(0)>>1)); err != nil || string(header) != "abc" {
+		t.Error("ReadFileHeader failed with a huge size on a short file:", "Got:", string(header), "Wanted:", "abc", err)
+	}
+
+	// Files under /proc report a size of 0 but still have contents
+	if stat, err := os.Stat("/proc/self/status"); err == nil && stat.Size() == 0 {
+		if header, err := ReadFileHeader("/proc/self/status", 5); err != nil || string(header) != "Name:" {
+			t.Error("ReadFileHeader failed on a file reporting a size of 0:", "Got:", string(header), "Wanted:", "Name:", err)
+		}
+	}
+	if header, err := ReadFileHeader(short, 0); err != nil || len(header) != 0 {
+		t.Error("ReadFileHeader failed for an empty header:", header, err)
+	}
+	if _, err := ReadFileHeader(tempDir+"/file-dne", 8); err == nil {
+		t.Error("ReadFileHeader succeeded with non-existent file")
+	}
+	if _, err := ReadFileHeader(short, -1); err == nil {
+		t.Error("ReadFileHeader succeeded with a negative size")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func benchmarkRead(b *testing.B, read func(string) ([]byte, error)) {
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var testFile = tempDir + "/test.file"
+	WriteFile(testFile, bytes.Repeat([]byte{'x'}, 4<<20), 0644)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := read(testFile); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadFileFast(b *testing.B) {
+	benchmarkRead(b, ReadFileFast)
+}
+
+func BenchmarkLoadFileBytes(b *testing.B) {
+	benchmarkRead(b, LoadFileBytes)
+}
+
+func TestReadFileIfModifiedSince(t *testing.T) {
+	color.Yellow("Testing conditional file reads")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var testFile = tempDir + "/config"
+	var loaded = time.Now().Add(-time.Hour)
+	WriteFile(testFile, []byte("original"), 0644)
+	os.Chtimes(testFile, loaded, loaded)
+
+	c, modTime, changed, err := ReadFileIfModifiedSince(testFile, loaded)
+	if err != nil || changed || c != nil {
+		t.Error("Unchanged file should not be read:", "Changed:", changed, "Contents:", c, err)
+	}
+	if !modTime.Equal(loaded) {
+		t.Error("Incorrect modification time:", "Got:", modTime, "Wanted:", loaded)
+	}
+
+	WriteFile(testFile, []byte("updated"), 0644)
+	c, modTime, changed, err = ReadFileIfModifiedSince(testFile, loaded)
+	if err != nil || !changed || string(c) != "updated" {
+		t.Error("Changed file should be read:", "Changed:", changed, "Contents:", string(c), err)
+	}
+	if !modTime.After(loaded) {
+		t.Error("Modification time should be newer:", "Got:", modTime)
+	}
+
+	if _, _, _, err := ReadFileIfModifiedSince(tempDir+"/file-dne", loaded); err == nil {
+		t.Error("ReadFileIfModifiedSince succeeded with non-existent file")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestLogTimings(t *testing.T) {
+	color.Yellow("Testing operation timing logs")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+	WriteFile(tempDir+"/test.file", []byte("test"), 0644)
+
+	var output bytes.Buffer
+	var l = logrus.New()
+	l.Out = &output
+	l.Formatter = &logrus.JSONFormatter{}
+	l.Level = logrus.DebugLevel
+	SetLogger(l)
+	defer SetLogger(logrus.New())
+
+	var timed = func() map[string]bool {
+		var found = map[string]bool{}
+		for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+			var entry map[string]interface{}
+			if json.Unmarshal([]byte(line), &entry) == nil && entry["duration"] != nil {
+				found[entry["msg"].(string)] = true
+			}
+		}
+		output.Reset()
+		return found
+	}
+	var run = func() {
+		CopyFile(tempDir+"/test.file", tempDir+"/copy.file")
+		GetFileSHA256Checksum(tempDir + "/test.file")
+		WalkDirectory(tempDir, func(path string, info os.FileInfo, err error) error { return err })
+	}
+
+	run()
+	if found := timed(); len(found) != 0 {
+		t.Error("Durations should not be logged unless enabled:", found)
+	}
+
+	SetLogTimings(true)
+	defer SetLogTimings(false)
+	run()
+	var found = timed()
+	for _, msg := range []string{"Copy finished", "Checksum finished", "Walk finished"} {
+		if !found[msg] {
+			t.Error("Missing duration log:", msg, "Got:", found)
+		}
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	color.Yellow("Testing atomic writes")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var testFile = tempDir + "/test.file"
+	for _, contents := range []string{"first", "second"} {
+		if err := WriteFileAtomic(testFile, []byte(contents), 0600); err != nil {
+			t.Error("WriteFileAtomic failed:", testFile, err)
+		}
+		if c, err := LoadFileString(testFile); err != nil || c != contents {
+			t.Error("File contents don't match what was saved:", "Got:", c, "Wanted:", contents)
+		}
+	}
+	if stat, err := os.Stat(testFile); err != nil {
+		t.Error("Could not stat file:", testFile, err)
+	} else if stat.Mode().Perm() != 0600 {
+		t.Error("Atomically written file has incorrect mode:", stat.Mode().Perm())
+	}
+	if c, err := GetDirectoryContents(tempDir); err != nil || len(c) != 1 {
+		t.Error("WriteFileAtomic left temp files behind:", c)
+	}
+
+	if err := WriteFileAtomic(tempDir+"/dne/expect-error", []byte("test"), 0644); err == nil {
+		t.Error("WriteFileAtomic should have failed but did not return an error")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestWriteFileAtomicFallback(t *testing.T) {
+	color.Yellow("Testing atomic write temp directory fallback")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var targetDir = tempDir + "/target"
+	var fallbackDir = tempDir + "/fallback"
+	var testFile = targetDir + "/test.file"
+	CreateDirectory(targetDir)
+	CreateDirectory(fallbackDir)
+
+	// Simulate a read-only target directory
+	var tempFile = osTempFile
+	defer func() {
+		osTempFile = tempFile
+		SetTempDir("")
+	}()
+	osTempFile = func(dir, pattern string) (writableFile, error) {
+		if dir == targetDir {
+			return nil, &os.PathError{Op: "open", Path: dir, Err: os.ErrPermission}
+		}
+		return tempFile(dir, pattern)
+	}
+
+	if err := WriteFileAtomic(testFile, []byte("test"), 0644); err == nil {
+		t.Error("WriteFileAtomic succeeded in an unwritable directory without a fallback")
+	}
+
+	// A new file can't be created in an unwritable directory, even with a fallback
+	SetTempDir(fallbackDir)
+	if err := WriteFileAtomic(testFile, []byte("test"), 0600); err == nil {
+		t.Error("WriteFileAtomic created a file in an unwritable directory")
+	}
+	if CheckExists(testFile) {
+		t.Error("Failed write created the file:", testFile)
+	}
+
+	// An existing file is rewritten in place
+	WriteFile(testFile, []byte("previous contents"), 0644)
+	before, _ := os.Stat(testFile)
+	if err := WriteFileAtomic(testFile, []byte("test"), 0600); err != nil {
+		t.Error("WriteFileAtomic failed with a fallback temp directory:", testFile, err)
+	}
+	if c, err := LoadFileString(testFile); err != nil || c != "test" {
+		t.Error("File contents don't match what was saved:", "Got:", c, "Wanted:", "test")
+	}
+	if stat, err := os.Stat(testFile); err != nil {
+		t.Error("Could not stat file:", testFile, err)
+	} else if stat.Mode().Perm() != 0600 {
+		t.Error("File written through fallback has incorrect mode:", stat.Mode().Perm())
+	} else if !os.SameFile(before, stat) {
+		t.Error("File written through fallback was replaced rather than rewritten in place")
+	}
+	if c, err := GetDirectoryContents(fallbackDir); err != nil || len(c) != 0 {
+		t.Error("WriteFileAtomic left temp files in the fallback directory:", c)
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestWriteFileIfChanged(t *testing.T) {
+	color.Yellow("Testing conditional writes")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var testFile = tempDir + "/test.file"
+	if written, err := WriteFileIfChanged(testFile, []byte("test"), 0644); err != nil || !written {
+		t.Error("WriteFileIfChanged should write a new file:", written, err)
+	}
+	var past = time.Now().Add(-time.Hour).Truncate(time.Second)
+	os.Chtimes(testFile, past, past)
+
+	if written, err := WriteFileIfChanged(testFile, []byte("test"), 0644); err != nil || written {
+		t.Error("WriteFileIfChanged should skip identical content:", written, err)
+	}
+	if stat, err := os.Stat(testFile); err != nil || !stat.ModTime().Equal(past) {
+		t.Error("Skipped write changed the modification time")
+	}
+
+	if written, err := WriteFileIfChanged(testFile, []byte("changed"), 0644); err != nil || !written {
+		t.Error("WriteFileIfChanged should write changed content:", written, err)
+	}
+	if c, err := LoadFileString(testFile); err != nil || c != "changed" {
+		t.Error("File contents don't match what was saved:", "Got:", c, "Wanted:", "changed")
+	}
+	if stat, err := os.Stat(testFile); err != nil || stat.ModTime().Equal(past) {
+		t.Error("Write did not update the modification time")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+// shortWriter simulates a disk filling up after the first few bytes of a write
+type shortWriter struct {
+	writableFile
+}
+
+func (w shortWriter) Write(p []byte) (int, error) {
+	if len(p) > 2 {
+		n, _ := w.writableFile.Write(p[:2])
+		return n, syscall.ENOSPC
+	}
+	return w.writableFile.Write(p)
+}
+
+func TestPartialWriteCleanup(t *testing.T) {
+	color.Yellow("Testing cleanup after partial writes")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var openFile, tempFile = osOpenFile, osTempFile
+	defer func() {
+		osOpenFile, osTempFile = openFile, tempFile
+	}()
+	osOpenFile = func(name string, flag int, perm os.FileMode) (writableFile, error) {
+		f, err := openFile(name, flag, perm)
+		if err != nil {
+			return nil, err
+		}
+		return shortWriter{f}, nil
+	}
+	osTempFile = func(dir, pattern string) (writableFile, error) {
+		f, err := tempFile(dir, pattern)
+		if err != nil {
+			return nil, err
+		}
+		return shortWriter{f}, nil
+	}
+
+	var testFile = tempDir + "/test.file"
+	if err := WriteFile(testFile, []byte("test"), 0644); err != syscall.ENOSPC {
+		t.Error("WriteFile should report the write error:", "Got:", err)
+	}
+	if CheckExists(testFile) {
+		t.Error("WriteFile left a partially written file behind:", testFile)
+	}
+
+	if err := WriteFileAtomic(testFile, []byte("test"), 0644); err != syscall.ENOSPC {
+		t.Error("WriteFileAtomic should report the write error:", "Got:", err)
+	}
+	if c, err := GetDirectoryContents(tempDir); err != nil || len(c) != 0 {
+		t.Error("WriteFileAtomic left a partially written file behind:", c)
+	}
+	color.Yellow("Test Complete")
+	println()
 }
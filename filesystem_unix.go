@@ -0,0 +1,183 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+)
+
+// spaceSafetyMargin is the free space HasSpaceFor leaves untouched on top of the requested size
+const spaceSafetyMargin = 1 << 20
+
+// copyOwnership applies the uid/gid recorded in info to dst
+func copyOwnership(info os.FileInfo, dst string) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ErrUnsupportedPlatform
+	}
+	return os.Chown(dst, int(stat.Uid), int(stat.Gid))
+}
+
+// fileOwner returns the uid that owns the entry described by info
+func fileOwner(info os.FileInfo) (int, error) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, ErrUnsupportedPlatform
+	}
+	return int(stat.Uid), nil
+}
+
+// GetInodeUsage returns the total and free inode counts for the filesystem containing path
+func GetInodeUsage(path string) (uint64, uint64, error) {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	var fields = logrus.Fields{
+		"path": logPath(path),
+	}
+
+	logger.WithFields(fields).Debug("Retrieving inode usage")
+	var stat syscall.Statfs_t
+	if err = syscall.Statfs(path, &stat); err != nil {
+		logger.WithFields(fields).Warn("Failed to retrieve inode usage")
+		return 0, 0, err
+	}
+	return uint64(stat.Files), uint64(stat.Ffree), nil
+}
+
+// GetInode returns the inode number of path, which stays the same across renames on the same filesystem
+func GetInode(path string) (uint64, error) {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return 0, err
+	}
+	var fields = logrus.Fields{
+		"path": logPath(path),
+	}
+
+	logger.WithFields(fields).Debug("Retrieving inode")
+	var stat syscall.Stat_t
+	if err = syscall.Stat(path, &stat); err != nil {
+		logger.WithFields(fields).Warn("Failed to retrieve inode")
+		return 0, err
+	}
+	return uint64(stat.Ino), nil
+}
+
+// GetLinkCount returns the number of hard links to path
+// A count above 1 means other names refer to the same inode
+func GetLinkCount(path string) (uint64, error) {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return 0, err
+	}
+	var fields = logrus.Fields{
+		"path": logPath(path),
+	}
+
+	logger.WithFields(fields).Debug("Retrieving link count")
+	var stat syscall.Stat_t
+	if err = syscall.Stat(path, &stat); err != nil {
+		logger.WithFields(fields).Warn("Failed to retrieve link count")
+		return 0, err
+	}
+	return uint64(stat.Nlink), nil
+}
+
+// GetSparseSize returns the logical size of path and the number of bytes actually allocated for it on disk
+func GetSparseSize(path string) (int64, int64, error) {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	var fields = logrus.Fields{
+		"path": logPath(path),
+	}
+
+	logger.WithFields(fields).Debug("Retrieving sparse file size")
+	var stat syscall.Stat_t
+	if err = syscall.Stat(path, &stat); err != nil {
+		logger.WithFields(fields).Warn("Failed to retrieve sparse file size")
+		return 0, 0, err
+	}
+	// st_blocks is always counted in 512-byte units, regardless of the filesystem block size
+	return stat.Size, int64(stat.Blocks) * 512, nil
+}
+
+// HasSpaceFor reports whether the filesystem containing path has room for size more bytes, keeping
+// spaceSafetyMargin free; if path doesn't exist yet, its nearest existing parent directory is checked
+// Only space available to unprivileged users is counted
+func HasSpaceFor(path string, size int64) (bool, error) {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return false, err
+	}
+	var fields = logrus.Fields{
+		"path":  logPath(path),
+		"bytes": size,
+	}
+
+	logger.WithFields(fields).Debug("Checking available space")
+	var stat syscall.Statfs_t
+	for {
+		err = syscall.Statfs(path, &stat)
+		if err != syscall.ENOENT || filepath.Dir(path) == path {
+			break
+		}
+		path = filepath.Dir(path)
+	}
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to check available space")
+		return false, err
+	}
+	var available = uint64(stat.Bavail) * uint64(stat.Bsize)
+	fields["available"] = available
+	if size < 0 {
+		size = 0
+	}
+	var hasSpace = uint64(size)+spaceSafetyMargin <= available
+	logger.WithFields(fields).Debug("Checked available space")
+	return hasSpace, nil
+}
+
+// lockFile takes an exclusive advisory lock on f, blocking until it's available
+func lockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+}
+
+// openNoFollow opens path with O_NOFOLLOW so a symlink at the final component is never followed
+func openNoFollow(path string, flag int, mode os.FileMode) (*os.File, error) {
+	f, err := os.OpenFile(path, flag|syscall.O_NOFOLLOW, mode)
+	if err != nil {
+		if stat, statErr := os.Lstat(path); statErr == nil && stat.Mode()&os.ModeSymlink != 0 {
+			return nil, &ErrSymlinkDestination{Path: path}
+		}
+	}
+	return f, err
+}
+
+// pathLimits returns the longest path (PATH_MAX, including the terminating NUL) and longest path component
+// (NAME_MAX) the platform accepts
+func pathLimits() (int, int) {
+	if runtime.GOOS == "darwin" {
+		return 1024, 255
+	}
+	return 4096, 255
+}
+
+// unlockFile releases the advisory lock held on f
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
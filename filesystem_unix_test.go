@@ -0,0 +1,668 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package filesystem
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestInodeUsage(t *testing.T) {
+	color.Yellow("Testing inode usage")
+	total, free, err := GetInodeUsage("/tmp/")
+	if err != nil {
+		t.Error("GetInodeUsage failed:", err)
+	}
+	if total < free {
+		t.Error("Free inodes exceed total:", "Total:", total, "Free:", free)
+	}
+	if _, _, err := GetInodeUsage("/tmp/.filesystem-test-dne/"); err == nil {
+		t.Error("GetInodeUsage succeeded with non-existent path")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestCreateDirectoryWithExactMode(t *testing.T) {
+	color.Yellow("Testing umask-independent directory creation")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var oldUmask = syscall.Umask(0077)
+	defer syscall.Umask(oldUmask)
+
+	var dir = tempDir + "/umask-applied"
+	if err := CreateDirectoryWithMode(dir, 0777); err != nil {
+		t.Error("CreateDirectoryWithMode failed:", dir, err)
+	}
+	if stat, err := os.Stat(dir); err != nil {
+		t.Error("Could not stat directory:", dir, err)
+	} else if stat.Mode().Perm() != 0700 {
+		t.Error("CreateDirectoryWithMode should apply the umask:", dir, "Got:", stat.Mode().Perm(), "Wanted:", os.FileMode(0700))
+	}
+
+	dir = tempDir + "/exact/with/children"
+	if err := CreateDirectoryWithExactMode(dir, 0777); err != nil {
+		t.Error("CreateDirectoryWithExactMode failed:", dir, err)
+	}
+	for _, d := range []string{tempDir + "/exact", tempDir + "/exact/with", dir} {
+		if stat, err := os.Stat(d); err != nil {
+			t.Error("Could not stat directory:", d, err)
+		} else if stat.Mode().Perm() != 0777 {
+			t.Error("Directory mode incorrect:", d, "Got:", stat.Mode().Perm(), "Wanted:", os.FileMode(0777))
+		}
+	}
+	if stat, err := os.Stat(tempDir); err != nil || stat.Mode().Perm() != 0700 {
+		t.Error("Pre-existing parent directory mode should not change:", tempDir)
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestWriteFileNoFollow(t *testing.T) {
+	color.Yellow("Testing symlink-safe writes")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var target = tempDir + "/target.file"
+	var link = tempDir + "/link.file"
+	WriteFile(target, []byte("original"), 0644)
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+
+	err = WriteFileNoFollow(link, []byte("overwritten"), 0644)
+	if _, ok := err.(*ErrSymlinkDestination); !ok {
+		t.Error("WriteFileNoFollow should refuse to write through a symlink:", "Got:", err)
+	}
+	if c, _ := LoadFileString(target); c != "original" {
+		t.Error("Symlink target was modified:", "Got:", c, "Wanted:", "original")
+	}
+
+	var regular = tempDir + "/regular.file"
+	if err := WriteFileNoFollow(regular, []byte("test"), 0644); err != nil {
+		t.Error("WriteFileNoFollow failed on a regular path:", regular, err)
+	}
+	if c, _ := LoadFileString(regular); c != "test" {
+		t.Error("File contents don't match what was saved:", "Got:", c, "Wanted:", "test")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestPartialWriteCleanupSymlink(t *testing.T) {
+	color.Yellow("Testing cleanup after partial writes through symlinks")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var target = tempDir + "/target.file"
+	var link = tempDir + "/link.file"
+	WriteFile(target, []byte("original"), 0644)
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+
+	var openFile = osOpenFile
+	defer func() {
+		osOpenFile = openFile
+	}()
+	osOpenFile = func(name string, flag int, perm os.FileMode) (writableFile, error) {
+		f, err := openFile(name, flag, perm)
+		if err != nil {
+			return nil, err
+		}
+		return shortWriter{f}, nil
+	}
+
+	if err := WriteFile(link, []byte("overwritten"), 0644); err != syscall.ENOSPC {
+		t.Error("WriteFile should report the write error:", "Got:", err)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Error("WriteFile removed the symlink after a failed write:", err)
+	}
+	if err := WriteAndVerify(link, []byte("overwritten"), 0644); err != syscall.ENOSPC {
+		t.Error("WriteAndVerify should report the write error:", "Got:", err)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Error("WriteAndVerify removed the symlink after a failed write:", err)
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestLoadFileReadErrors(t *testing.T) {
+	color.Yellow("Testing read errors from file loads")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	// Unreadable files (unless running as root) and /proc/self/mem (unmapped at offset 0) fail part way into the read
+	var unreadable = tempDir + "/unreadable.file"
+	WriteFile(unreadable, []byte("test"), 0000)
+	var paths = []string{"/proc/self/mem"}
+	if os.Geteuid() != 0 {
+		paths = append(paths, unreadable)
+	}
+	for _, path := range paths {
+		if !CheckExists(path) {
+			continue
+		}
+		if _, err := LoadFileBytes(path); err == nil {
+			t.Error("LoadFileBytes should report the read error:", path)
+		}
+		if _, err := LoadFileString(path); err == nil {
+			t.Error("LoadFileString should report the read error:", path)
+		}
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestDefaultModes(t *testing.T) {
+	color.Yellow("Testing default file and directory modes")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var oldUmask = syscall.Umask(0)
+	defer syscall.Umask(oldUmask)
+
+	var checkMode = func(path string, wanted os.FileMode) {
+		if stat, err := os.Stat(path); err != nil {
+			t.Error("Could not stat path:", path, err)
+		} else if stat.Mode().Perm() != wanted {
+			t.Error("Mode incorrect:", path, "Got:", stat.Mode().Perm(), "Wanted:", wanted)
+		}
+	}
+
+	// Built-in defaults
+	WriteFile(tempDir+"/builtin.file", []byte("test"), 0)
+	checkMode(tempDir+"/builtin.file", 0644)
+	CreateDirectory(tempDir + "/builtin")
+	checkMode(tempDir+"/builtin", 0755)
+
+	// Configured defaults
+	SetDefaultFileMode(0600)
+	SetDefaultDirMode(0700)
+	defer SetDefaultFileMode(0)
+	defer SetDefaultDirMode(0)
+	WriteFile(tempDir+"/configured.file", []byte("test"), 0)
+	checkMode(tempDir+"/configured.file", 0600)
+	CreateDirectory(tempDir + "/configured")
+	checkMode(tempDir+"/configured", 0700)
+	CreateDirectoryWithMode(tempDir+"/configured-with-mode", 0)
+	checkMode(tempDir+"/configured-with-mode", 0700)
+	WriteFile(tempDir+"/stored.file", []byte("test"), 0644)
+	if object, err := StoreByChecksum(tempDir+"/stored.file", tempDir+"/store"); err != nil {
+		t.Error("StoreByChecksum failed:", err)
+	} else {
+		checkMode(tempDir+"/store/"+object, 0600)
+	}
+
+	// Explicit modes always win
+	WriteFile(tempDir+"/explicit.file", []byte("test"), 0640)
+	checkMode(tempDir+"/explicit.file", 0640)
+	CreateDirectoryWithMode(tempDir+"/explicit", 0750)
+	checkMode(tempDir+"/explicit", 0750)
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestSparseFiles(t *testing.T) {
+	color.Yellow("Testing sparse files")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var sparseFile = tempDir + "/sparse.img"
+	var size = int64(100 * 1024 * 1024)
+	if err := CreateSparseFile(sparseFile, size, 0644); err != nil {
+		t.Fatal("CreateSparseFile failed:", sparseFile, err)
+	}
+	logical, allocated, err := GetSparseSize(sparseFile)
+	if err != nil {
+		t.Fatal("GetSparseSize failed:", sparseFile, err)
+	}
+	if logical != size {
+		t.Error("Sparse file has incorrect logical size:", "Got:", logical, "Wanted:", size)
+	}
+	if allocated > 1024*1024 {
+		t.Error("Sparse file has too much space allocated on disk:", allocated)
+	}
+
+	if _, _, err := GetSparseSize(tempDir + "/file-dne"); err == nil {
+		t.Error("GetSparseSize succeeded with non-existent file")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestCanWriteToDirectory(t *testing.T) {
+	color.Yellow("Testing directory writability")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	if !CanWriteToDirectory(tempDir) {
+		t.Error("Temp directory should be writable:", tempDir)
+	}
+	if c, err := GetDirectoryContents(tempDir); err != nil || len(c) != 0 {
+		t.Error("CanWriteToDirectory left files behind:", c)
+	}
+	if CanWriteToDirectory(tempDir + "/dne") {
+		t.Error("Non-existent directory should not be writable")
+	}
+
+	if os.Geteuid() == 0 {
+		t.Skip("Read-only directories are writable as root")
+	}
+	var readOnly = tempDir + "/read-only"
+	CreateDirectoryWithExactMode(readOnly, 0555)
+	if CanWriteToDirectory(readOnly) {
+		t.Error("Read-only directory should not be writable:", readOnly)
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestCopyPermissions(t *testing.T) {
+	color.Yellow("Testing permission copies")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var src = tempDir + "/template.file"
+	var dst = tempDir + "/generated.file"
+	WriteFile(src, []byte("template"), 0644)
+	WriteFile(dst, []byte("generated"), 0644)
+	os.Chmod(src, 0751)
+
+	if err := CopyPermissions(src, dst); err != nil {
+		t.Error("CopyPermissions failed:", src, dst, err)
+	}
+	srcStat, _ := os.Stat(src)
+	dstStat, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dstStat.Mode() != srcStat.Mode() {
+		t.Error("Mode was not copied:", "Got:", dstStat.Mode(), "Wanted:", srcStat.Mode())
+	}
+	var srcOwner = srcStat.Sys().(*syscall.Stat_t)
+	var dstOwner = dstStat.Sys().(*syscall.Stat_t)
+	if srcOwner.Uid != dstOwner.Uid || srcOwner.Gid != dstOwner.Gid {
+		t.Error("Ownership was not copied:", "Got:", dstOwner.Uid, dstOwner.Gid, "Wanted:", srcOwner.Uid, srcOwner.Gid)
+	}
+
+	if err := CopyPermissions(tempDir+"/file-dne", dst); err == nil {
+		t.Error("CopyPermissions succeeded with non-existent source")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestListRegularFiles(t *testing.T) {
+	color.Yellow("Testing regular file listing")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	CreateDirectory(tempDir + "/nested")
+	WriteFile(tempDir+"/a.file", []byte("test"), 0644)
+	WriteFile(tempDir+"/nested/b.file", []byte("test"), 0644)
+	if err := syscall.Mkfifo(tempDir+"/fifo", 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Symlink(tempDir+"/a.file", tempDir+"/link")
+
+	files, err := ListRegularFiles(tempDir)
+	var wanted = []string{tempDir + "/a.file", tempDir + "/nested/b.file"}
+	if err != nil || !reflect.DeepEqual(files, wanted) {
+		t.Error("ListRegularFiles returned incorrect files:", "Got:", files, "Wanted:", wanted, err)
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestVerifyModes(t *testing.T) {
+	color.Yellow("Testing mode verification")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	CreateDirectoryWithExactMode(tempDir+"/bin", 0755)
+	WriteFile(tempDir+"/bin/tool", []byte("test"), 0755)
+	WriteFile(tempDir+"/secret", []byte("test"), 0600)
+	WriteFile(tempDir+"/config", []byte("test"), 0600)
+	WriteFile(tempDir+"/unlisted", []byte("test"), 0777)
+	os.Chmod(tempDir+"/bin/tool", 0755)
+	os.Chmod(tempDir+"/secret", 0600)
+	os.Chmod(tempDir+"/config", 0666)
+
+	mismatched, err := VerifyModes(tempDir, map[string]os.FileMode{
+		"bin":      0755,
+		"bin/tool": 0755,
+		"secret":   0600,
+		"config":   0644,
+		"missing":  0644,
+	})
+	var wanted = []string{"config", "missing"}
+	if err != nil || !reflect.DeepEqual(mismatched, wanted) {
+		t.Error("VerifyModes reported incorrect paths:", "Got:", mismatched, "Wanted:", wanted, err)
+	}
+
+	if _, err := VerifyModes(tempDir+"/dne", map[string]os.FileMode{}); err == nil {
+		t.Error("VerifyModes succeeded with non-existent directory")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestWriteFileMode(t *testing.T) {
+	color.Yellow("Testing writes with special mode bits")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var testFile = tempDir + "/test.file"
+	if err := WriteFileMode(testFile, []byte("test"), 0644|os.ModeSticky); err != nil {
+		t.Error("WriteFileMode failed:", testFile, err)
+	}
+	if stat, err := os.Stat(testFile); err != nil {
+		t.Error("Could not stat file:", testFile, err)
+	} else if stat.Mode()&os.ModeSticky == 0 || stat.Mode().Perm() != 0644 {
+		t.Error("Special mode bits were not applied:", "Got:", stat.Mode(), "Wanted:", 0644|os.ModeSticky)
+	}
+	if c, err := LoadFileString(testFile); err != nil || c != "test" {
+		t.Error("File contents don't match what was saved:", "Got:", c, "Wanted:", "test")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestGetLinkCount(t *testing.T) {
+	color.Yellow("Testing hard link counts")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var testFile = tempDir + "/test.file"
+	var link = tempDir + "/link.file"
+	WriteFile(testFile, []byte("test"), 0644)
+	if err := os.Link(testFile, link); err != nil {
+		t.Fatal("Could not create hard link:", err)
+	}
+	if n, err := GetLinkCount(testFile); err != nil || n != 2 {
+		t.Error("Unexpected link count:", "Got:", n, "Wanted:", 2, err)
+	}
+
+	DeleteFile(link)
+	if n, err := GetLinkCount(testFile); err != nil || n != 1 {
+		t.Error("Unexpected link count after removing link:", "Got:", n, "Wanted:", 1, err)
+	}
+	if _, err := GetLinkCount(link); err == nil {
+		t.Error("GetLinkCount succeeded with non-existent file")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestHasSpaceFor(t *testing.T) {
+	color.Yellow("Testing available space checks")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	if ok, err := HasSpaceFor(tempDir, 1024); err != nil || !ok {
+		t.Error("HasSpaceFor reported no space for a small write:", err)
+	}
+	if ok, err := HasSpaceFor(tempDir+"/dne/test.file", 1024); err != nil || !ok {
+		t.Error("HasSpaceFor failed for a file that doesn't exist yet:", err)
+	}
+	if ok, err := HasSpaceFor(tempDir, 1<<62); err != nil || ok {
+		t.Error("HasSpaceFor reported space for an absurdly large write:", err)
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestCopyFileSparse(t *testing.T) {
+	color.Yellow("Testing sparse file copies")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var src = tempDir + "/sparse.img"
+	var dst = tempDir + "/copy.img"
+	var size = int64(100 * 1024 * 1024)
+	if err := CreateSparseFile(src, size, 0644); err != nil {
+		t.Fatal("CreateSparseFile failed:", src, err)
+	}
+	f, err := os.OpenFile(src, os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteAt([]byte("header"), 0)
+	f.WriteAt([]byte("middle"), size/2)
+	f.Close()
+
+	if err := CopyFileSparse(src, dst); err != nil {
+		t.Fatal("CopyFileSparse failed:", src, dst, err)
+	}
+	logical, allocated, err := GetSparseSize(dst)
+	if err != nil {
+		t.Fatal("GetSparseSize failed:", dst, err)
+	}
+	if logical != size {
+		t.Error("Sparse copy has incorrect logical size:", "Got:", logical, "Wanted:", size)
+	}
+	if allocated > 1024*1024 {
+		t.Error("Sparse copy has too much space allocated on disk:", allocated)
+	}
+	srcSum, _ := GetFileSHA256Checksum(src)
+	if dstSum, err := GetFileSHA256Checksum(dst); err != nil || dstSum != srcSum {
+		t.Error("Sparse copy contents don't match the source:", "Got:", dstSum, "Wanted:", srcSum, err)
+	}
+
+	if err := CopyFileSparse(tempDir+"/file-dne", dst); err == nil {
+		t.Error("CopyFileSparse succeeded with non-existent file")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestWriteFileOwned(t *testing.T) {
+	color.Yellow("Testing writes with ownership")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var testFile = tempDir + "/test.file"
+
+	// The fallback temp directory would leave an existing file with its old owner, so it's never used
+	WriteFile(testFile, []byte("previous contents"), 0644)
+	var tempFile = osTempFile
+	osTempFile = func(dir, pattern string) (writableFile, error) {
+		if dir == tempDir {
+			return nil, &os.PathError{Op: "open", Path: dir, Err: os.ErrPermission}
+		}
+		return tempFile(dir, pattern)
+	}
+	SetTempDir(tempDir + "/fallback")
+	CreateDirectory(tempDir + "/fallback")
+	err = WriteFileOwned(testFile, []byte("test"), 0644, 1, 1)
+	osTempFile = tempFile
+	SetTempDir("")
+	RemoveDirectory(tempDir+"/fallback", true)
+	if !os.IsPermission(err) {
+		t.Error("WriteFileOwned should fail in an unwritable directory:", "Got:", err)
+	}
+	if c, _ := LoadFileString(testFile); c != "previous contents" {
+		t.Error("WriteFileOwned modified the file through the fallback directory:", "Got:", c)
+	}
+	DeleteFile(testFile)
+
+	if os.Geteuid() != 0 {
+		if err := WriteFileOwned(testFile, []byte("test"), 0644, 1, 1); !os.IsPermission(err) {
+			t.Error("WriteFileOwned should return a permission error without privileges:", "Got:", err)
+		}
+		if CheckExists(testFile) {
+			t.Error("WriteFileOwned left a file behind after failing:", testFile)
+		}
+		t.Skip("Changing ownership requires root")
+	}
+
+	if err := WriteFileOwned(testFile, []byte("test"), 0640, 1, 1); err != nil {
+		t.Error("WriteFileOwned failed:", testFile, err)
+	}
+	if stat, err := os.Stat(testFile); err != nil {
+		t.Error("Could not stat file:", testFile, err)
+	} else if sys := stat.Sys().(*syscall.Stat_t); sys.Uid != 1 || sys.Gid != 1 || stat.Mode().Perm() != 0640 {
+		t.Error("File has incorrect ownership or mode:", "Got:", sys.Uid, sys.Gid, stat.Mode().Perm(), "Wanted:", 1, 1, os.FileMode(0640))
+	}
+	if c, err := LoadFileString(testFile); err != nil || c != "test" {
+		t.Error("File contents don't match what was saved:", "Got:", c, "Wanted:", "test")
+	}
+	if c, err := GetDirectoryContents(tempDir); err != nil || len(c) != 1 {
+		t.Error("WriteFileOwned left temp files behind:", c)
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestCreateDirectorySecure(t *testing.T) {
+	color.Yellow("Testing directory creation with ownership checks")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var dir = tempDir + "/a/b"
+	if err := CreateDirectorySecure(dir, os.Geteuid()); err != nil {
+		t.Error("CreateDirectorySecure failed:", dir, err)
+	}
+	if !IsDirectory(dir) {
+		t.Error("CreateDirectorySecure did not create the directory:", dir)
+	}
+
+	// Parents other users can write to are only trusted when sticky
+	var shared = tempDir + "/shared"
+	CreateDirectory(shared)
+	os.Chmod(shared, 0777)
+	err = CreateDirectorySecure(shared+"/child", os.Geteuid())
+	if insecureErr, ok := err.(*ErrInsecureDirectory); !ok || insecureErr.Path != shared {
+		t.Error("CreateDirectorySecure should return *ErrInsecureDirectory:", "Got:", err)
+	}
+	if CheckExists(shared + "/child") {
+		t.Error("CreateDirectorySecure created a directory under a world-writable parent")
+	}
+	os.Chmod(shared, 0777|os.ModeSticky)
+	if err := CreateDirectorySecure(shared+"/child", os.Geteuid()); err != nil {
+		t.Error("CreateDirectorySecure failed under a sticky parent:", err)
+	}
+
+	if os.Geteuid() != 0 {
+		t.Skip("Changing ownership requires root")
+	}
+	var untrusted = tempDir + "/untrusted"
+	CreateDirectory(untrusted)
+	if err := os.Chown(untrusted, 1, 1); err != nil {
+		t.Fatal("Could not change directory ownership:", err)
+	}
+	err = CreateDirectorySecure(untrusted+"/child", 0)
+	if ownerErr, ok := err.(*ErrUnexpectedOwner); !ok {
+		t.Error("CreateDirectorySecure should return *ErrUnexpectedOwner:", "Got:", err)
+	} else if ownerErr.Path != untrusted || ownerErr.Actual != 1 {
+		t.Error("Ownership error has incorrect details:", ownerErr)
+	}
+	if CheckExists(untrusted + "/child") {
+		t.Error("CreateDirectorySecure created a directory under an untrusted parent")
+	}
+
+	// A directory another user created first in a sticky parent is caught
+	CreateDirectory(shared + "/taken")
+	os.Chown(shared+"/taken", 1, 1)
+	if _, ok := CreateDirectorySecure(shared+"/taken", 0).(*ErrUnexpectedOwner); !ok {
+		t.Error("CreateDirectorySecure accepted a directory owned by another user")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestGetInode(t *testing.T) {
+	color.Yellow("Testing inode numbers")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var testFile = tempDir + "/test.file"
+	var link = tempDir + "/link.file"
+	var other = tempDir + "/other.file"
+	WriteFile(testFile, []byte("test"), 0644)
+	WriteFile(other, []byte("test"), 0644)
+	if err := os.Link(testFile, link); err != nil {
+		t.Fatal("Could not create hard link:", err)
+	}
+
+	inode, err := GetInode(testFile)
+	if err != nil {
+		t.Fatal("GetInode failed:", testFile, err)
+	}
+	if linkInode, err := GetInode(link); err != nil || linkInode != inode {
+		t.Error("Hard links have different inodes:", "Got:", linkInode, "Wanted:", inode, err)
+	}
+	if otherInode, err := GetInode(other); err != nil || otherInode == inode {
+		t.Error("Distinct files share an inode:", otherInode, err)
+	}
+	os.Rename(testFile, tempDir+"/renamed.file")
+	if renamedInode, err := GetInode(tempDir + "/renamed.file"); err != nil || renamedInode != inode {
+		t.Error("Inode changed after rename:", "Got:", renamedInode, "Wanted:", inode, err)
+	}
+	if _, err := GetInode(testFile); err == nil {
+		t.Error("GetInode succeeded with non-existent file")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
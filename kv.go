@@ -0,0 +1,150 @@
+package filesystem
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Each key/value pair is stored in its own file, named by the SHA-256 checksum of the key so no key can
+// escape the store directory or produce an invalid file name.  Since the name can't be reversed, the file
+// starts with the quoted key on its own line, followed by the value
+
+// KVDelete removes key from the store in storeDir
+func KVDelete(storeDir, key string) error {
+	path, err := kvPath(storeDir, key)
+	if err != nil {
+		return err
+	}
+	var fields = logrus.Fields{
+		"store": logPath(filepath.Dir(path)),
+		"key":   key,
+	}
+
+	logger.WithFields(fields).Debug("Deleting key")
+	if err = os.Remove(path); err != nil {
+		logger.WithFields(fields).Warn("Failed to delete key")
+		return err
+	}
+	return nil
+}
+
+// KVGet returns the value stored for key in storeDir
+// A missing key returns an error satisfying os.IsNotExist
+func KVGet(storeDir, key string) ([]byte, error) {
+	path, err := kvPath(storeDir, key)
+	if err != nil {
+		return nil, err
+	}
+	var fields = logrus.Fields{
+		"store": logPath(filepath.Dir(path)),
+		"key":   key,
+	}
+
+	logger.WithFields(fields).Debug("Getting key")
+	storedKey, value, err := readKVFile(path)
+	if err == nil && storedKey != key {
+		err = errors.New("stored key does not match: " + storedKey)
+	}
+	if err != nil {
+		logger.WithFields(fields).Info("Failed to get key")
+		return nil, err
+	}
+	return value, nil
+}
+
+// KVList returns the sorted keys stored in storeDir
+func KVList(storeDir string) ([]string, error) {
+	var err error
+	storeDir, err = BuildAbsolutePathFromHome(storeDir)
+	if err != nil {
+		return nil, err
+	}
+	var fields = logrus.Fields{
+		"store": logPath(storeDir),
+	}
+
+	logger.WithFields(fields).Debug("Listing keys")
+	var keys = []string{}
+	err = IterateDirectory(storeDir, func(info os.FileInfo) error {
+		// Skip anything that isn't a stored value, including in-progress atomic writes
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".") {
+			return nil
+		}
+		key, _, err := readKVFile(filepath.Join(storeDir, info.Name()))
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to list keys")
+		return nil, err
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// KVSet atomically stores value for key in storeDir, replacing any existing value
+// storeDir is created if it doesn't exist
+func KVSet(storeDir, key string, value []byte) error {
+	path, err := kvPath(storeDir, key)
+	if err != nil {
+		return err
+	}
+	var fields = logrus.Fields{
+		"store": logPath(filepath.Dir(path)),
+		"key":   key,
+	}
+
+	logger.WithFields(fields).Debug("Setting key")
+	if err = CreateDirectory(filepath.Dir(path)); err != nil {
+		logger.WithFields(fields).Warn("Failed to set key")
+		return err
+	}
+	var contents = append([]byte(strconv.Quote(key)+"\n"), value...)
+	if err = WriteFileAtomic(path, contents, 0); err != nil {
+		logger.WithFields(fields).Warn("Failed to set key")
+		return err
+	}
+	return nil
+}
+
+// kvPath returns the path of the file storing key in storeDir
+func kvPath(storeDir, key string) (string, error) {
+	storeDir, err := BuildAbsolutePathFromHome(storeDir)
+	if err != nil {
+		return "", err
+	}
+	var checksum = sha256.Sum256([]byte(key))
+	return filepath.Join(storeDir, hex.EncodeToString(checksum[:])), nil
+}
+
+// readKVFile returns the key and value stored in path
+func readKVFile(path string) (string, []byte, error) {
+	contents, err := LoadFileBytes(path)
+	if err != nil {
+		if !CheckExists(path) {
+			return "", nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+		}
+		return "", nil, err
+	}
+	var i = bytes.IndexByte(contents, '\n')
+	if i < 0 {
+		return "", nil, errors.New(path + " is not a key/value file")
+	}
+	key, err := strconv.Unquote(string(contents[:i]))
+	if err != nil {
+		return "", nil, errors.New(path + " is not a key/value file")
+	}
+	return key, contents[i+1:], nil
+}
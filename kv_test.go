@@ -0,0 +1,68 @@
+package filesystem
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestKeyValueStore(t *testing.T) {
+	color.Yellow("Testing key/value store")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+	var storeDir = tempDir + "/store"
+
+	// Round trips and overwrites
+	if err := KVSet(storeDir, "greeting", []byte("hello")); err != nil {
+		t.Fatal("KVSet failed:", err)
+	}
+	if v, err := KVGet(storeDir, "greeting"); err != nil || string(v) != "hello" {
+		t.Error("KVGet returned incorrect value:", "Got:", string(v), "Wanted:", "hello", err)
+	}
+	KVSet(storeDir, "greeting", []byte("hello\nagain"))
+	if v, err := KVGet(storeDir, "greeting"); err != nil || string(v) != "hello\nagain" {
+		t.Error("KVGet returned incorrect value after overwrite:", "Got:", string(v), err)
+	}
+	KVSet(storeDir, "empty", []byte{})
+	if v, err := KVGet(storeDir, "empty"); err != nil || len(v) != 0 {
+		t.Error("KVGet returned incorrect empty value:", "Got:", v, err)
+	}
+
+	// Malicious keys stay inside the store
+	for _, key := range []string{"../x", "/etc/passwd", "a/b\x00c", "..", ""} {
+		if err := KVSet(storeDir, key, []byte(key)); err != nil {
+			t.Error("KVSet failed:", key, err)
+		}
+		if v, err := KVGet(storeDir, key); err != nil || string(v) != key {
+			t.Error("KVGet returned incorrect value:", key, "Got:", string(v), err)
+		}
+	}
+	if c, err := GetDirectoryContents(tempDir); err != nil || !reflect.DeepEqual(c, []string{"store"}) {
+		t.Error("A key escaped the store directory:", c)
+	}
+
+	keys, err := KVList(storeDir)
+	var wanted = []string{"", "..", "../x", "/etc/passwd", "a/b\x00c", "empty", "greeting"}
+	if err != nil || !reflect.DeepEqual(keys, wanted) {
+		t.Errorf("KVList returned incorrect keys: Got: %q Wanted: %q %v", keys, wanted, err)
+	}
+
+	// Deletes
+	if err := KVDelete(storeDir, "greeting"); err != nil {
+		t.Error("KVDelete failed:", err)
+	}
+	if _, err := KVGet(storeDir, "greeting"); !os.IsNotExist(err) {
+		t.Error("KVGet should report a deleted key as not existing:", "Got:", err)
+	}
+	if err := KVDelete(storeDir, "greeting"); err == nil {
+		t.Error("KVDelete succeeded with a missing key")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
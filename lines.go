@@ -0,0 +1,252 @@
+package filesystem
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxDiffLines is the number of differing lines DiffFile reports before truncating its output
+const maxDiffLines = 100
+
+// maxLineLength is the longest line DiffFile and ReadLineRange will read
+const maxLineLength = 1024 * 1024
+
+// reverseReadBlockSize is the size of the blocks read from the end of a file by ReadFileLinesReverse
+const reverseReadBlockSize = 4096
+
+// DiffFile compares the contents of path against expected and, when they differ, describes where in a
+// unified-diff-like format (--- path, +++ expected, then @@ hunks of - and + lines)
+// Lines are compared by position as both are streamed, so memory use stays bounded for large files, but an
+// inserted or removed line shows up as every following line changing rather than as a minimal diff
+// Output stops after maxDiffLines differing lines
+func DiffFile(path string, expected []byte) (identical bool, diff string, err error) {
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return false, "", err
+	}
+	var fields = logrus.Fields{
+		"file": logPath(path),
+	}
+
+	logger.WithFields(fields).Debug("Comparing file contents")
+	if !isFile(path) {
+		err = errors.New(path + " is not a file")
+		logger.WithFields(fields).Info("Could not read file")
+		return false, "", err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		logger.WithFields(fields).Info("Could not read file")
+		return false, "", err
+	}
+	defer f.Close()
+
+	var actualLines = newLineScanner(f)
+	var expectedLines = newLineScanner(bytes.NewReader(expected))
+	var out bytes.Buffer
+	var removed, added []string
+	var hunkStart, differing = 0, 0
+	var flush = func() {
+		if len(removed) == 0 && len(added) == 0 {
+			return
+		}
+		if out.Len() == 0 {
+			out.WriteString("--- " + path + "\n+++ expected\n")
+		}
+		out.WriteString("@@ -" + strconv.Itoa(hunkStart) + "," + strconv.Itoa(len(removed)) +
+			" +" + strconv.Itoa(hunkStart) + "," + strconv.Itoa(len(added)) + " @@\n")
+		for _, line := range removed {
+			writeDiffLine(&out, "-", line)
+		}
+		for _, line := range added {
+			writeDiffLine(&out, "+", line)
+		}
+		removed, added = nil, nil
+	}
+	for n := 1; ; n++ {
+		var hasActual, hasExpected = actualLines.Scan(), expectedLines.Scan()
+		if !hasActual && !hasExpected {
+			break
+		}
+		if hasActual && hasExpected && actualLines.Text() == expectedLines.Text() {
+			flush()
+			continue
+		}
+		if differing == maxDiffLines {
+			flush()
+			out.WriteString("... diff truncated\n")
+			break
+		}
+		differing++
+		if len(removed) == 0 && len(added) == 0 {
+			hunkStart = n
+		}
+		if hasActual {
+			removed = append(removed, actualLines.Text())
+		}
+		if hasExpected {
+			added = append(added, expectedLines.Text())
+		}
+	}
+	flush()
+	if err = actualLines.Err(); err != nil {
+		logger.WithFields(fields).Info("Could not read file")
+		return false, "", err
+	}
+	if err = expectedLines.Err(); err != nil {
+		return false, "", err
+	}
+	return out.Len() == 0, out.String(), nil
+}
+
+// newLineScanner returns a scanner over the lines of r that keeps line endings, so lines that only differ in
+// their endings (or a missing final newline) don't compare as equal
+func newLineScanner(r io.Reader) *bufio.Scanner {
+	var scanner = bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineLength)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			return i + 1, data[:i+1], nil
+		}
+		if atEOF && len(data) > 0 {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	})
+	return scanner
+}
+
+// writeDiffLine writes line to out with prefix, noting when the line has no trailing newline
+func writeDiffLine(out *bytes.Buffer, prefix, line string) {
+	out.WriteString(prefix + line)
+	if !strings.HasSuffix(line, "\n") {
+		out.WriteString("\n\\ No newline at end of file\n")
+	}
+}
+
+// ReadLineRange returns lines start through end (1-based, inclusive) of path
+// The file is streamed, so only the requested lines are held in memory
+// An *ErrLineRange is returned if start < 1, start > end, or end is past the last line of the file; lines longer
+// than 1MiB return bufio.ErrTooLong
+func ReadLineRange(path string, start, end int) ([]string, error) {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return nil, err
+	}
+	var fields = logrus.Fields{
+		"file":  logPath(path),
+		"start": start,
+		"end":   end,
+	}
+
+	logger.WithFields(fields).Debug("Reading line range")
+	if start < 1 || start > end {
+		logger.WithFields(fields).Info("Invalid line range")
+		return nil, &ErrLineRange{Path: path, Start: start, End: end}
+	}
+	if !isFile(path) {
+		err = errors.New(path + " is not a file")
+		logger.WithFields(fields).Info("Could not read file")
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		logger.WithFields(fields).Info("Could not read file")
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines = []string{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, maxLineLength)
+	for n := 1; n <= end && scanner.Scan(); n++ {
+		if n >= start {
+			lines = append(lines, scanner.Text())
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		logger.WithFields(fields).Info("Could not read file")
+		return nil, err
+	}
+	if len(lines) != end-start+1 {
+		logger.WithFields(fields).Info("Line range extends past the end of the file")
+		return nil, &ErrLineRange{Path: path, Start: start, End: end}
+	}
+	return lines, nil
+}
+
+// ReadFileLinesReverse calls fn for each line of path, starting with the last line and ending with the first
+// The file is read from the end in blocks, so only the lines being processed are held in memory
+// Line endings are stripped (matching bufio.ScanLines); a missing trailing newline is handled
+// Iteration stops with the error returned by fn, if any
+func ReadFileLinesReverse(path string, fn func(line string) error) error {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return err
+	}
+	var fields = logrus.Fields{
+		"file": logPath(path),
+	}
+
+	logger.WithFields(fields).Debug("Reading file lines in reverse")
+	if !isFile(path) {
+		err = errors.New(path + " is not a file")
+		logger.WithFields(fields).Info("Could not read file")
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		logger.WithFields(fields).Info("Could not read file")
+		return err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		logger.WithFields(fields).Info("Could not read file")
+		return err
+	}
+
+	var emit = func(line []byte) error {
+		return fn(string(bytes.TrimSuffix(line, []byte{'\r'})))
+	}
+
+	var partial []byte
+	for pos := stat.Size(); pos > 0; {
+		var n = int64(reverseReadBlockSize)
+		if pos < n {
+			n = pos
+		}
+		pos -= n
+		var buf = make([]byte, n, n+int64(len(partial)))
+		if _, err = f.ReadAt(buf, pos); err != nil {
+			logger.WithFields(fields).Info("Could not read file")
+			return err
+		}
+		buf = append(buf, partial...)
+
+		// The newline terminating the final line doesn't start another line
+		if pos+n == stat.Size() && len(buf) > 0 && buf[len(buf)-1] == '\n' {
+			buf = buf[:len(buf)-1]
+		}
+		for i := bytes.LastIndexByte(buf, '\n'); i >= 0; i = bytes.LastIndexByte(buf, '\n') {
+			if err = emit(buf[i+1:]); err != nil {
+				return err
+			}
+			buf = buf[:i]
+		}
+		partial = buf
+	}
+	if stat.Size() > 0 {
+		return emit(partial)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package filesystem
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestDiffFile(t *testing.T) {
+	color.Yellow("Testing file diffs")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var testFile = tempDir + "/test.file"
+	var contents = "first\nsecond\nthird\nfourth\nfifth\n"
+	WriteFile(testFile, []byte(contents), 0644)
+
+	identical, diff, err := DiffFile(testFile, []byte(contents))
+	if err != nil || !identical || diff != "" {
+		t.Error("Identical contents reported as different:", "Got:", identical, diff, err, "Wanted:", true)
+	}
+
+	identical, diff, err = DiffFile(testFile, []byte("first\nSECOND\nthird\nFOURTH\nFIFTH\nsixth"))
+	if err != nil || identical {
+		t.Error("Changed contents reported as identical:", "Got:", identical, err, "Wanted:", false)
+	}
+	var wanted = "--- " + testFile + "\n+++ expected\n" +
+		"@@ -2,1 +2,1 @@\n-second\n+SECOND\n" +
+		"@@ -4,2 +4,3 @@\n-fourth\n-fifth\n+FOURTH\n+FIFTH\n+sixth\n\\ No newline at end of file\n"
+	if diff != wanted {
+		t.Errorf("Diff doesn't match: Got: %q Wanted: %q", diff, wanted)
+	}
+
+	// A missing trailing newline is a difference
+	identical, _, err = DiffFile(testFile, []byte(strings.TrimSuffix(contents, "\n")))
+	if err != nil || identical {
+		t.Error("Missing trailing newline reported as identical:", "Got:", identical, err, "Wanted:", false)
+	}
+
+	// Output is bounded
+	_, diff, err = DiffFile(testFile, []byte(strings.Repeat("x\n", maxDiffLines*2)))
+	if err != nil || !strings.HasSuffix(diff, "... diff truncated\n") {
+		t.Error("Long diff not truncated:", "Got:", err, "Wanted:", nil)
+	}
+
+	if _, _, err = DiffFile(tempDir, nil); err == nil {
+		t.Error("Diffing a directory should fail")
+	}
+
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestReadFileLinesReverse(t *testing.T) {
+	color.Yellow("Testing reverse line reads")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var long = strings.Repeat("a long line that will cross block boundaries\n", 500)
+	var testData = []string{
+		"",
+		"\n",
+		"single line",
+		"first\nsecond\nthird\n",
+		"first\nsecond\nthird",
+		"first\r\n\r\nthird\r\n",
+		"\n\nblank lines before",
+		long,
+		long + "no trailing newline",
+	}
+
+	var testFile = tempDir + "/test.file"
+	for _, contents := range testData {
+		WriteFile(testFile, []byte(contents), 0644)
+
+		var wanted = []string{}
+		scanner := bufio.NewScanner(strings.NewReader(contents))
+		for scanner.Scan() {
+			wanted = append([]string{scanner.Text()}, wanted...)
+		}
+		var got = []string{}
+		err := ReadFileLinesReverse(testFile, func(line string) error {
+			got = append(got, line)
+			return nil
+		})
+		if err != nil {
+			t.Error("ReadFileLinesReverse failed:", err)
+		}
+		if !reflect.DeepEqual(got, wanted) {
+			t.Errorf("Reversed lines don't match for %q: Got: %q Wanted: %q", contents, got, wanted)
+		}
+	}
+
+	// Stop early when the callback returns an error
+	WriteFile(testFile, []byte("first\nsecond\nthird\n"), 0644)
+	var stop = errors.New("stop")
+	var got = []string{}
+	err = ReadFileLinesReverse(testFile, func(line string) error {
+		got = append(got, line)
+		return stop
+	})
+	if err != stop || !reflect.DeepEqual(got, []string{"third"}) {
+		t.Error("ReadFileLinesReverse should stop on callback error:", "Got:", got, err)
+	}
+
+	if err := ReadFileLinesReverse(tempDir+"/file-dne", func(string) error { return nil }); err == nil {
+		t.Error("ReadFileLinesReverse succeeded with non-existent file")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestReadLineRange(t *testing.T) {
+	color.Yellow("Testing line range reads")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var testFile = tempDir + "/test.file"
+	WriteFile(testFile, []byte("one\ntwo\nthree\nfour\nfive\n"), 0644)
+
+	var testData = []struct {
+		start, end int
+		expected   []string
+	}{
+		{1, 1, []string{"one"}},
+		{2, 4, []string{"two", "three", "four"}},
+		{4, 5, []string{"four", "five"}},
+		{1, 5, []string{"one", "two", "three", "four", "five"}},
+	}
+	for _, test := range testData {
+		got, err := ReadLineRange(testFile, test.start, test.end)
+		if err != nil || !reflect.DeepEqual(got, test.expected) {
+			t.Error("ReadLineRange failed:", test.start, test.end, "Got:", got, "Wanted:", test.expected, err)
+		}
+	}
+
+	for _, bounds := range [][2]int{{0, 2}, {3, 2}, {4, 6}, {6, 6}} {
+		_, err := ReadLineRange(testFile, bounds[0], bounds[1])
+		if _, ok := err.(*ErrLineRange); !ok {
+			t.Error("ReadLineRange should return *ErrLineRange:", bounds, "Got:", err)
+		}
+	}
+
+	if _, err := ReadLineRange(tempDir+"/file-dne", 1, 1); err == nil {
+		t.Error("ReadLineRange succeeded with non-existent file")
+	}
+
+	// Lines longer than the default scanner buffer are read
+	var long = strings.Repeat("x", 256*1024)
+	WriteFile(testFile, []byte("first\n"+long+"\nthird\n"), 0644)
+	if lines, err := ReadLineRange(testFile, 2, 3); err != nil || len(lines) != 2 || lines[0] != long || lines[1] != "third" {
+		t.Error("ReadLineRange failed with a long line:", "Got:", len(lines), err)
+	}
+	color.Yellow("Test Complete")
+	println()
+}
@@ -0,0 +1,298 @@
+package filesystem
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// AppendJSONLine marshals v and appends it to path as a single line, creating the file if needed
+// The write happens while holding an exclusive advisory lock (flock), so lines from concurrent writers
+// using AppendJSONLine never interleave
+// A mode of 0 uses the default file mode (see SetDefaultFileMode)
+func AppendJSONLine(path string, v interface{}, mode os.FileMode) error {
+	var err error
+	mode = fileMode(mode)
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return err
+	}
+	var fields = logrus.Fields{
+		"file": logPath(path),
+		"mode": mode,
+	}
+
+	logger.WithFields(fields).Debug("Appending JSON line")
+	line, err := json.Marshal(v)
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to marshal JSON line")
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, mode)
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to append JSON line")
+		return err
+	}
+	defer f.Close()
+
+	if err = lockFile(f); err != nil {
+		logger.WithFields(fields).Warn("Failed to lock file")
+		return err
+	}
+	defer unlockFile(f)
+
+	if _, err = f.Write(append(line, '\n')); err != nil {
+		logger.WithFields(fields).Warn("Failed to append JSON line")
+		return err
+	}
+	logger.WithFields(fields).Debug("JSON line appended successfully")
+	return nil
+}
+
+// IncrementCounterFile increments the integer stored in path and returns the new value
+// A missing or empty file counts as 0; the update happens while holding an exclusive advisory lock (flock) on the
+// sidecar file path + ".lock", so concurrent callers (including other processes) each receive a distinct value
+// The new value is written with WriteFileAtomic, so a crash part way leaves the previous value in place
+// Advisory locks are only supported on Linux and macOS
+func IncrementCounterFile(path string) (int64, error) {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return 0, err
+	}
+	var fields = logrus.Fields{
+		"file": logPath(path),
+	}
+
+	logger.WithFields(fields).Debug("Incrementing counter file")
+	lock, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, fileMode(0))
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to open lock file")
+		return 0, err
+	}
+	defer lock.Close()
+
+	if err = lockFile(lock); err != nil {
+		logger.WithFields(fields).Warn("Failed to lock file")
+		return 0, err
+	}
+	defer unlockFile(lock)
+
+	old, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		logger.WithFields(fields).Warn("Failed to read counter file")
+		return 0, err
+	}
+	var value int64
+	if trimmed := strings.TrimSpace(string(old)); trimmed != "" {
+		if value, err = strconv.ParseInt(trimmed, 10, 64); err != nil {
+			logger.WithFields(fields).Warn("Counter file does not contain an integer")
+			return 0, err
+		}
+	}
+	value++
+	if err = WriteFileAtomic(path, []byte(strconv.FormatInt(value, 10)), 0); err != nil {
+		logger.WithFields(fields).Warn("Failed to write counter file")
+		return 0, err
+	}
+	fields["value"] = value
+	logger.WithFields(fields).Debug("Counter file incremented")
+	return value, nil
+}
+
+// UpdateFileLocked performs a read-modify-write of path while holding an exclusive advisory lock (flock)
+// fn receives the current contents (empty if the file is new) and returns the replacement contents
+// The read, truncate, and write all happen on a single handle, so other processes using UpdateFileLocked
+// on the same file never observe or lose a partial update; if fn returns an error, the file is left untouched
+// (a file created by this call is removed again)
+// A mode of 0 uses the default file mode (see SetDefaultFileMode)
+// Advisory locks are only supported on Linux and macOS
+func UpdateFileLocked(path string, fn func(old []byte) ([]byte, error), mode os.FileMode) error {
+	var err error
+	mode = fileMode(mode)
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return err
+	}
+	var fields = logrus.Fields{
+		"file": logPath(path),
+		"mode": mode,
+	}
+
+	logger.WithFields(fields).Debug("Updating file under lock")
+	f, created, err := openLockedForUpdate(path, mode)
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to update file")
+		return err
+	}
+	defer f.Close()
+	defer unlockFile(f)
+
+	err = updateLockedFile(f, fn)
+	if err != nil {
+		if created {
+			// Still holding the lock, so no other caller has read or written the file yet
+			os.Remove(path)
+		}
+		logger.WithFields(fields).Warn("Failed to update file")
+		return err
+	}
+	logger.WithFields(fields).Debug("File updated successfully")
+	return nil
+}
+
+// WriteAndVerify writes data to path in place, then reads it back and returns ErrVerificationFailed if the
+// contents differ (i.e. a sysfs or device attribute that silently clamps or rejects the value)
+// The write and read-back happen while holding an exclusive advisory lock (flock) on path, so cooperating
+// writers can't change the value in between
+// A mode of 0 uses the default file mode (see SetDefaultFileMode)
+// Advisory locks are only supported on Linux and macOS
+func WriteAndVerify(path string, data []byte, mode os.FileMode) error {
+	var err error
+	mode = fileMode(mode)
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return err
+	}
+	var fields = logrus.Fields{
+		"file": logPath(path),
+		"mode": mode,
+	}
+
+	logger.WithFields(fields).Debug("Writing file with verification")
+	lock, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, mode)
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to write file")
+		return err
+	}
+	defer lock.Close()
+
+	if err = lockFile(lock); err != nil {
+		logger.WithFields(fields).Warn("Failed to lock file")
+		return err
+	}
+	defer unlockFile(lock)
+
+	if err = WriteFile(path, data, mode); err != nil {
+		logger.WithFields(fields).Warn("Failed to write file")
+		return err
+	}
+	f, err := osOpen(path)
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to read back file")
+		return err
+	}
+	defer f.Close()
+	actual, err := ioutil.ReadAll(f)
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to read back file")
+		return err
+	}
+	if !bytes.Equal(actual, data) {
+		logger.WithFields(fields).Warn("File did not read back with the written contents")
+		return ErrVerificationFailed
+	}
+	logger.WithFields(fields).Debug("File written and verified successfully")
+	return nil
+}
+
+// WriteFileExclusiveLocked atomically replaces path with data while holding an exclusive advisory lock (flock)
+// on the sidecar file path + ".lock", which is created if needed and left in place afterwards
+// Writers using WriteFileExclusiveLocked on the same path are serialized, and since the write is atomic,
+// readers that simply open path always see either the previous or the new contents in full
+// Readers that must not observe a file while a new version is being produced (for example, to read several
+// related files consistently) should take a shared flock on path + ".lock" for the duration of their reads
+// A mode of 0 uses the default file mode (see SetDefaultFileMode)
+// Advisory locks are only supported on Linux and macOS
+func WriteFileExclusiveLocked(path string, data []byte, mode os.FileMode) error {
+	var err error
+	mode = fileMode(mode)
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return err
+	}
+	var fields = logrus.Fields{
+		"file": logPath(path),
+		"mode": mode,
+	}
+
+	logger.WithFields(fields).Debug("Writing file under lock")
+	lock, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, mode)
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to open lock file")
+		return err
+	}
+	defer lock.Close()
+
+	if err = lockFile(lock); err != nil {
+		logger.WithFields(fields).Warn("Failed to lock file")
+		return err
+	}
+	defer unlockFile(lock)
+
+	if err = WriteFileAtomic(path, data, mode); err != nil {
+		logger.WithFields(fields).Warn("Failed to write file")
+		return err
+	}
+	logger.WithFields(fields).Debug("File written successfully")
+	return nil
+}
+
+// openLockedForUpdate opens path for reading and writing, creating it with mode if needed, and takes an exclusive
+// lock on it; created reports whether this call created the file
+// If path was removed or replaced while waiting for the lock (i.e. by a failed UpdateFileLocked that created it),
+// the lock is released and the open retried, so the update never lands in an unlinked file
+func openLockedForUpdate(path string, mode os.FileMode) (f *os.File, created bool, err error) {
+	for {
+		created = true
+		f, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, mode)
+		if os.IsExist(err) {
+			created = false
+			if f, err = os.OpenFile(path, os.O_RDWR, mode); os.IsNotExist(err) {
+				continue
+			}
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if err = lockFile(f); err != nil {
+			f.Close()
+			return nil, false, err
+		}
+		var opened os.FileInfo
+		if opened, err = f.Stat(); err != nil {
+			unlockFile(f)
+			f.Close()
+			return nil, false, err
+		}
+		if current, err := os.Stat(path); err == nil && os.SameFile(opened, current) {
+			return f, created, nil
+		}
+		unlockFile(f)
+		f.Close()
+	}
+}
+
+// updateLockedFile replaces the contents of f with the result of fn
+func updateLockedFile(f *os.File, fn func(old []byte) ([]byte, error)) error {
+	old, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	data, err := fn(old)
+	if err != nil {
+		return err
+	}
+	if err = f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err = f.WriteAt(data, 0); err != nil {
+		return err
+	}
+	return f.Sync()
+}
@@ -0,0 +1,283 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package filesystem
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+func TestUpdateFileLocked(t *testing.T) {
+	color.Yellow("Testing locked file updates")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var counter = tempDir + "/counter"
+	var increment = func(old []byte) ([]byte, error) {
+		var n = 0
+		if len(old) > 0 {
+			var err error
+			if n, err = strconv.Atoi(string(old)); err != nil {
+				return nil, err
+			}
+		}
+		return []byte(strconv.Itoa(n + 1)), nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := UpdateFileLocked(counter, increment, 0644); err != nil {
+				t.Error("UpdateFileLocked failed:", counter, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if c, err := LoadFileString(counter); err != nil || c != "50" {
+		t.Error("Concurrent updates were lost:", "Got:", c, "Wanted:", "50")
+	}
+
+	var failure = errors.New("update rejected")
+	if err := UpdateFileLocked(counter, func(old []byte) ([]byte, error) { return nil, failure }, 0644); err != failure {
+		t.Error("UpdateFileLocked should return the update function's error:", "Got:", err)
+	}
+	if c, _ := LoadFileString(counter); c != "50" {
+		t.Error("Failed update modified the file:", "Got:", c, "Wanted:", "50")
+	}
+
+	// A failed update doesn't leave a new file behind
+	var missing = tempDir + "/missing"
+	if err := UpdateFileLocked(missing, func(old []byte) ([]byte, error) { return nil, failure }, 0644); err != failure {
+		t.Error("UpdateFileLocked should return the update function's error:", "Got:", err)
+	}
+	if CheckExists(missing) {
+		t.Error("Failed update left a new file behind:", missing)
+	}
+
+	// A mode of 0 uses the default file mode
+	var oldUmask = syscall.Umask(0)
+	defer syscall.Umask(oldUmask)
+	var defaulted = tempDir + "/defaulted"
+	if err := UpdateFileLocked(defaulted, increment, 0); err != nil {
+		t.Error("UpdateFileLocked failed:", defaulted, err)
+	}
+	if stat, err := os.Stat(defaulted); err != nil {
+		t.Error("Could not stat path:", defaulted, err)
+	} else if stat.Mode().Perm() != 0644 {
+		t.Error("UpdateFileLocked used the wrong mode:", "Got:", stat.Mode().Perm(), "Wanted:", os.FileMode(0644))
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestIncrementCounterFile(t *testing.T) {
+	color.Yellow("Testing counter files")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var counter = tempDir + "/counter"
+	const increments = 50
+	var values = make(chan int64, increments)
+	var wg sync.WaitGroup
+	for i := 0; i < increments; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, err := IncrementCounterFile(counter)
+			if err != nil {
+				t.Error("IncrementCounterFile failed:", counter, err)
+			}
+			values <- n
+		}()
+	}
+	wg.Wait()
+	close(values)
+
+	var seen = map[int64]bool{}
+	for n := range values {
+		if seen[n] {
+			t.Error("IncrementCounterFile returned a duplicate value:", n)
+		}
+		seen[n] = true
+	}
+	for n := int64(1); n <= increments; n++ {
+		if !seen[n] {
+			t.Error("IncrementCounterFile skipped a value:", n)
+		}
+	}
+	if c, err := LoadFileString(counter); err != nil || c != strconv.Itoa(increments) {
+		t.Error("Counter file has unexpected value:", "Got:", c, "Wanted:", increments)
+	}
+	if contents, _ := GetDirectoryContents(tempDir); !reflect.DeepEqual(contents, []string{"counter", "counter.lock"}) {
+		t.Error("Unexpected files after incrementing:", "Got:", contents, "Wanted:", []string{"counter", "counter.lock"})
+	}
+
+	WriteFile(counter, []byte("not a number"), 0644)
+	if _, err := IncrementCounterFile(counter); err == nil {
+		t.Error("IncrementCounterFile succeeded with a non-numeric counter")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestAppendJSONLine(t *testing.T) {
+	color.Yellow("Testing JSON line appends")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	type event struct {
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+	var eventLog = tempDir + "/events.jsonl"
+	const events = 50
+	var wg sync.WaitGroup
+	for i := 0; i < events; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			var message = strings.Repeat("multi\nline ", 100)
+			if err := AppendJSONLine(eventLog, event{id, message}, 0644); err != nil {
+				t.Error("AppendJSONLine failed:", eventLog, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	contents, err := LoadFileString(eventLog)
+	if err != nil {
+		t.Fatal("Could not read event log:", err)
+	}
+	var lines = strings.Split(strings.TrimSuffix(contents, "\n"), "\n")
+	if len(lines) != events {
+		t.Error("Unexpected number of lines:", "Got:", len(lines), "Wanted:", events)
+	}
+	var seen = map[int]bool{}
+	for _, line := range lines {
+		var e event
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Error("Line is not valid JSON:", line, err)
+		}
+		seen[e.ID] = true
+	}
+	if len(seen) != events {
+		t.Error("Events were lost:", "Got:", len(seen), "Wanted:", events)
+	}
+
+	if err := AppendJSONLine(eventLog, make(chan int), 0644); err == nil {
+		t.Error("AppendJSONLine succeeded with a value that can't be marshaled")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestWriteFileExclusiveLocked(t *testing.T) {
+	color.Yellow("Testing exclusively locked file writes")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var artifact = tempDir + "/artifact"
+	var first = []byte(strings.Repeat("a", 1024*1024))
+	var second = []byte(strings.Repeat("b", 1024*1024))
+	var wg sync.WaitGroup
+	for _, data := range [][]byte{first, second} {
+		wg.Add(1)
+		go func(data []byte) {
+			defer wg.Done()
+			if err := WriteFileExclusiveLocked(artifact, data, 0644); err != nil {
+				t.Error("WriteFileExclusiveLocked failed:", artifact, err)
+			}
+		}(data)
+	}
+	wg.Wait()
+	contents, err := LoadFileString(artifact)
+	if err != nil || (contents != string(first) && contents != string(second)) {
+		t.Error("File does not contain one writer's complete data:", "Got:", len(contents), "bytes", err)
+	}
+
+	// A writer waits while the lock is held elsewhere
+	lock, err := os.OpenFile(artifact+".lock", os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal("Lock file was not created:", err)
+	}
+	defer lock.Close()
+	if err = lockFile(lock); err != nil {
+		t.Fatal(err)
+	}
+	var done = make(chan error)
+	go func() {
+		done <- WriteFileExclusiveLocked(artifact, []byte("locked"), 0644)
+	}()
+	select {
+	case <-done:
+		t.Error("WriteFileExclusiveLocked did not wait for the lock")
+	case <-time.After(100 * time.Millisecond):
+	}
+	unlockFile(lock)
+	if err = <-done; err != nil {
+		t.Error("WriteFileExclusiveLocked failed:", artifact, err)
+	}
+	if c, _ := LoadFileString(artifact); c != "locked" {
+		t.Error("File has unexpected contents:", "Got:", c, "Wanted:", "locked")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestWriteAndVerify(t *testing.T) {
+	color.Yellow("Testing verified writes")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var attribute = tempDir + "/brightness"
+	if err = WriteAndVerify(attribute, []byte("150\n"), 0644); err != nil {
+		t.Error("WriteAndVerify failed:", attribute, err)
+	}
+	if c, _ := LoadFileString(attribute); c != "150\n" {
+		t.Error("File has unexpected contents:", "Got:", c, "Wanted:", "150\n")
+	}
+
+	// Simulate a device that clamps the written value
+	var open = osOpen
+	defer func() {
+		osOpen = open
+	}()
+	osOpen = func(name string) (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader("100\n")), nil
+	}
+	if err = WriteAndVerify(attribute, []byte("255\n"), 0644); err != ErrVerificationFailed {
+		t.Error("WriteAndVerify should detect a clamped value:", "Got:", err, "Wanted:", ErrVerificationFailed)
+	}
+	color.Yellow("Test Complete")
+	println()
+}
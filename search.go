@@ -0,0 +1,137 @@
+package filesystem
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxSearchLineLength is the longest line SearchInFiles will scan
+const maxSearchLineLength = 1024 * 1024
+
+// FindByRegex returns the paths of the entries under root whose names match re
+// re is matched against each entry's base name only; directories are matched as well as files
+// Only entries directly inside root are considered unless recursive is set
+func FindByRegex(root string, re *regexp.Regexp, recursive bool) ([]string, error) {
+	var err error
+	root, err = BuildAbsolutePathFromHome(root)
+	if err != nil {
+		return nil, err
+	}
+	var fields = logrus.Fields{
+		"directory": logPath(root),
+		"pattern":   re.String(),
+		"recursive": recursive,
+	}
+
+	logger.WithFields(fields).Debug("Finding entries by name")
+	if !isDirectory(root) {
+		err = errors.New(root + " is not a directory")
+		logger.WithFields(fields).Warn("Failed to find entries")
+		return nil, err
+	}
+
+	var matches = []string{}
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+		if re.MatchString(info.Name()) {
+			matches = append(matches, path)
+		}
+		if info.IsDir() && !recursive {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to find entries")
+		return nil, err
+	}
+	fields["matches"] = len(matches)
+	logger.WithFields(fields).Debug("Found entries")
+	return matches, nil
+}
+
+// SearchInFiles finds the lines matching re in the files under root
+// Returns the 1-based numbers of the matching lines, keyed by file path; files without matches are omitted
+// Only files directly inside root are searched unless recursive is set; binary files (see IsBinaryFile) are skipped
+func SearchInFiles(root string, re *regexp.Regexp, recursive bool) (map[string][]int, error) {
+	var err error
+	root, err = BuildAbsolutePathFromHome(root)
+	if err != nil {
+		return nil, err
+	}
+	var fields = logrus.Fields{
+		"directory": logPath(root),
+		"pattern":   re.String(),
+		"recursive": recursive,
+	}
+
+	logger.WithFields(fields).Debug("Searching files")
+	if !isDirectory(root) {
+		err = errors.New(root + " is not a directory")
+		logger.WithFields(fields).Warn("Failed to search files")
+		return nil, err
+	}
+
+	var matches = map[string][]int{}
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != root && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		if binary, err := IsBinaryFile(path); err != nil || binary {
+			return err
+		}
+		lines, err := searchFile(path, re)
+		if err != nil {
+			return err
+		}
+		if len(lines) > 0 {
+			matches[path] = lines
+		}
+		return nil
+	})
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to search files")
+		return nil, err
+	}
+	fields["matches"] = len(matches)
+	logger.WithFields(fields).Debug("Search complete")
+	return matches, nil
+}
+
+// searchFile returns the 1-based numbers of the lines in path matching re
+func searchFile(path string, re *regexp.Regexp) ([]int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []int
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), maxSearchLineLength)
+	for n := 1; scanner.Scan(); n++ {
+		if re.Match(scanner.Bytes()) {
+			lines = append(lines, n)
+		}
+	}
+	return lines, scanner.Err()
+}
@@ -0,0 +1,89 @@
+package filesystem
+
+import (
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestSearchInFiles(t *testing.T) {
+	color.Yellow("Testing file content search")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	CreateDirectory(tempDir + "/nested")
+	WriteFile(tempDir+"/main.go", []byte("package main\n\n// TODO: fix\nfunc main() {}\n// TODO: test\n"), 0644)
+	WriteFile(tempDir+"/README", []byte("nothing to do here\n"), 0644)
+	WriteFile(tempDir+"/binary.bin", []byte("TODO\x00binary"), 0644)
+	WriteFile(tempDir+"/nested/util.go", []byte("// TODO: nested"), 0644)
+
+	var re = regexp.MustCompile(`TODO`)
+	matches, err := SearchInFiles(tempDir, re, false)
+	if err != nil {
+		t.Error("SearchInFiles failed:", tempDir, err)
+	}
+	var wanted = map[string][]int{tempDir + "/main.go": {3, 5}}
+	if !reflect.DeepEqual(matches, wanted) {
+		t.Error("Non-recursive search returned incorrect matches:", "Got:", matches, "Wanted:", wanted)
+	}
+
+	matches, err = SearchInFiles(tempDir, re, true)
+	if err != nil {
+		t.Error("SearchInFiles failed:", tempDir, err)
+	}
+	wanted[tempDir+"/nested/util.go"] = []int{1}
+	if !reflect.DeepEqual(matches, wanted) {
+		t.Error("Recursive search returned incorrect matches:", "Got:", matches, "Wanted:", wanted)
+	}
+
+	if binary, err := IsBinaryFile(tempDir + "/binary.bin"); err != nil || !binary {
+		t.Error("IsBinaryFile should detect binary content:", err)
+	}
+	if binary, err := IsBinaryFile(tempDir + "/main.go"); err != nil || binary {
+		t.Error("IsBinaryFile reported a text file as binary:", err)
+	}
+
+	if _, err := SearchInFiles(tempDir+"/dne", re, true); err == nil {
+		t.Error("SearchInFiles succeeded with non-existent directory")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestFindByRegex(t *testing.T) {
+	color.Yellow("Testing regex name matching")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	CreateDirectory(tempDir + "/archive")
+	for _, name := range []string{"log-2023.txt", "log-2024.txt", "log-24.txt", "log-2024.txt.bak", "notes.txt", "archive/log-2022.txt"} {
+		WriteFile(tempDir+"/"+name, []byte("test"), 0644)
+	}
+	var re = regexp.MustCompile(`^log-\d{4}\.txt$`)
+
+	matches, err := FindByRegex(tempDir, re, false)
+	if expected := []string{tempDir + "/log-2023.txt", tempDir + "/log-2024.txt"}; err != nil || !reflect.DeepEqual(matches, expected) {
+		t.Error("Unexpected matches:", "Got:", matches, "Wanted:", expected, err)
+	}
+	matches, err = FindByRegex(tempDir, re, true)
+	if expected := []string{tempDir + "/archive/log-2022.txt", tempDir + "/log-2023.txt", tempDir + "/log-2024.txt"}; err != nil || !reflect.DeepEqual(matches, expected) {
+		t.Error("Unexpected recursive matches:", "Got:", matches, "Wanted:", expected, err)
+	}
+	if matches, err := FindByRegex(tempDir, regexp.MustCompile(`^arch`), false); err != nil || len(matches) != 1 {
+		t.Error("Directories should be matched by name:", "Got:", matches, err)
+	}
+	if _, err := FindByRegex(tempDir+"/dir-dne", re, true); err == nil {
+		t.Error("FindByRegex succeeded with non-existent directory")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
@@ -0,0 +1,155 @@
+package filesystem
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DirectoriesEqual reports whether the trees at a and b have the same structure, along with the sorted
+// relative paths that differ (present in only one tree, or a file in one and a directory in the other)
+// When compareContents is true, files present in both trees are also compared by SHA-256 checksum
+func DirectoriesEqual(a, b string, compareContents bool) (bool, []string, error) {
+	var err error
+	a, err = BuildAbsolutePathFromHome(a)
+	if err != nil {
+		return false, nil, err
+	}
+	b, err = BuildAbsolutePathFromHome(b)
+	if err != nil {
+		return false, nil, err
+	}
+	var fields = logrus.Fields{
+		"first":    logPath(a),
+		"second":   logPath(b),
+		"contents": compareContents,
+	}
+
+	logger.WithFields(fields).Debug("Comparing directories")
+	first, err := SnapshotDirectory(a)
+	if err != nil {
+		return false, nil, err
+	}
+	second, err := SnapshotDirectory(b)
+	if err != nil {
+		return false, nil, err
+	}
+
+	var differences = []string{}
+	for relPath, firstStat := range first {
+		secondStat, ok := second[relPath]
+		if !ok || firstStat.IsDir != secondStat.IsDir {
+			differences = append(differences, relPath)
+			continue
+		}
+		if !compareContents || firstStat.IsDir {
+			continue
+		}
+		if firstStat.Size != secondStat.Size {
+			differences = append(differences, relPath)
+			continue
+		}
+		firstSum, err := fileSHA256Checksum(filepath.Join(a, relPath))
+		if err != nil {
+			return false, nil, err
+		}
+		secondSum, err := fileSHA256Checksum(filepath.Join(b, relPath))
+		if err != nil {
+			return false, nil, err
+		}
+		if firstSum != secondSum {
+			differences = append(differences, relPath)
+		}
+	}
+	for relPath := range second {
+		if _, ok := first[relPath]; !ok {
+			differences = append(differences, relPath)
+		}
+	}
+
+	sort.Strings(differences)
+	fields["differences"] = len(differences)
+	logger.WithFields(fields).Debug("Directory comparison complete")
+	return len(differences) == 0, differences, nil
+}
+
+// DiffSnapshots compares two snapshots taken by SnapshotDirectory
+// Returns the relative paths only present in b (added), only present in a (removed),
+// and present in both but differing (changed); each list is sorted
+// Files are considered changed when their size or modification time differs; directories
+// are only considered changed when they are replaced by a file (or vice versa), since their
+// contents are already reported individually
+func DiffSnapshots(a, b map[string]*FileStat) ([]string, []string, []string) {
+	var added = []string{}
+	var removed = []string{}
+	var changed = []string{}
+
+	for path, before := range a {
+		after, ok := b[path]
+		if !ok {
+			removed = append(removed, path)
+			continue
+		}
+		if before.IsDir != after.IsDir {
+			changed = append(changed, path)
+		} else if !before.IsDir && (before.Size != after.Size || !before.ModTime.Equal(after.ModTime)) {
+			changed = append(changed, path)
+		}
+	}
+	for path := range b {
+		if _, ok := a[path]; !ok {
+			added = append(added, path)
+		}
+	}
+
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(changed)
+	return added, removed, changed
+}
+
+// SnapshotDirectory records the metadata of every entry under root in a single walk
+// The returned map is keyed by the path relative to root; root itself is not included
+func SnapshotDirectory(root string) (map[string]*FileStat, error) {
+	var err error
+	root, err = BuildAbsolutePathFromHome(root)
+	if err != nil {
+		return nil, err
+	}
+	var fields = logrus.Fields{
+		"directory": logPath(root),
+	}
+
+	logger.WithFields(fields).Debug("Taking directory snapshot")
+	if !isDirectory(root) {
+		err = errors.New(root + " is not a directory")
+		logger.WithFields(fields).Warn("Failed to take directory snapshot")
+		return nil, err
+	}
+
+	var snapshot = map[string]*FileStat{}
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		snapshot[relPath] = newFileStat(info)
+		return nil
+	})
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to take directory snapshot")
+		return nil, err
+	}
+	fields["entries"] = len(snapshot)
+	logger.WithFields(fields).Debug("Directory snapshot complete")
+	return snapshot, nil
+}
@@ -0,0 +1,105 @@
+package filesystem
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+func TestDirectorySnapshots(t *testing.T) {
+	color.Yellow("Testing directory snapshots")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var past = time.Now().Add(-time.Hour)
+	CreateDirectory(tempDir + "/sub")
+	for _, name := range []string{"unchanged", "resized", "touched", "removed", "sub/nested"} {
+		WriteFile(tempDir+"/"+name, []byte("test"), 0644)
+		os.Chtimes(tempDir+"/"+name, past, past)
+	}
+
+	before, err := SnapshotDirectory(tempDir)
+	if err != nil {
+		t.Fatal("SnapshotDirectory failed:", err)
+	}
+	if len(before) != 6 {
+		t.Error("Snapshot has wrong number of entries:", "Got:", len(before), "Wanted:", 6)
+	}
+	if s, ok := before["sub/nested"]; !ok || s.IsDir || s.Size != 4 {
+		t.Error("Snapshot entry for nested file is incorrect:", s)
+	}
+
+	WriteFile(tempDir+"/resized", []byte("longer test"), 0644)
+	os.Chtimes(tempDir+"/resized", past, past)
+	os.Chtimes(tempDir+"/touched", time.Now(), time.Now())
+	DeleteFile(tempDir + "/removed")
+	WriteFile(tempDir+"/sub/added", []byte("test"), 0644)
+
+	after, err := SnapshotDirectory(tempDir)
+	if err != nil {
+		t.Fatal("SnapshotDirectory failed:", err)
+	}
+	added, removed, changed := DiffSnapshots(before, after)
+	if !reflect.DeepEqual(added, []string{"sub/added"}) {
+		t.Error("Added entries incorrect:", "Got:", added)
+	}
+	if !reflect.DeepEqual(removed, []string{"removed"}) {
+		t.Error("Removed entries incorrect:", "Got:", removed)
+	}
+	if !reflect.DeepEqual(changed, []string{"resized", "touched"}) {
+		t.Error("Changed entries incorrect:", "Got:", changed)
+	}
+
+	if _, err := SnapshotDirectory(tempDir + "/dne"); err == nil {
+		t.Error("SnapshotDirectory succeeded with non-existent directory")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestDirectoriesEqual(t *testing.T) {
+	color.Yellow("Testing directory comparison")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var first = tempDir + "/first"
+	var second = tempDir + "/second"
+	for _, dir := range []string{first, second} {
+		CreateDirectory(dir + "/sub")
+		WriteFile(dir+"/a.txt", []byte("test"), 0644)
+		WriteFile(dir+"/sub/b.txt", []byte("test"), 0644)
+	}
+
+	if equal, differences, err := DirectoriesEqual(first, second, true); err != nil || !equal || len(differences) != 0 {
+		t.Error("Identical directories reported as different:", differences, err)
+	}
+
+	DeleteFile(second + "/sub/b.txt")
+	if equal, differences, err := DirectoriesEqual(first, second, false); err != nil || equal || !reflect.DeepEqual(differences, []string{"sub/b.txt"}) {
+		t.Error("Missing file not detected:", "Got:", differences, "Wanted:", []string{"sub/b.txt"}, err)
+	}
+
+	WriteFile(second+"/sub/b.txt", []byte("tset"), 0644)
+	if equal, _, err := DirectoriesEqual(first, second, false); err != nil || !equal {
+		t.Error("Structural comparison should ignore file contents:", err)
+	}
+	if equal, differences, err := DirectoriesEqual(first, second, true); err != nil || equal || !reflect.DeepEqual(differences, []string{"sub/b.txt"}) {
+		t.Error("Content difference not detected:", "Got:", differences, "Wanted:", []string{"sub/b.txt"}, err)
+	}
+
+	if _, _, err := DirectoriesEqual(first, tempDir+"/dir-dne", false); err == nil {
+		t.Error("DirectoriesEqual succeeded with non-existent directory")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
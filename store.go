@@ -0,0 +1,93 @@
+package filesystem
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+)
+
+// StoreByChecksum copies srcFile into the content-addressed store at storeRoot
+// Objects are stored as storeRoot/ab/cdef... (matches the git objects layout)
+// The object is written atomically; if it already exists, the store is left untouched
+// Objects are created with the default file mode (see SetDefaultFileMode)
+// Returns the path of the object relative to storeRoot
+func StoreByChecksum(srcFile, storeRoot string) (string, error) {
+	var err error
+	srcFile, err = BuildAbsolutePathFromHome(srcFile)
+	if err != nil {
+		return "", err
+	}
+	storeRoot, err = BuildAbsolutePathFromHome(storeRoot)
+	if err != nil {
+		return "", err
+	}
+	var fields = logrus.Fields{
+		"source": logPath(srcFile),
+		"store":  logPath(storeRoot),
+	}
+
+	logger.WithFields(fields).Debug("Storing file by checksum")
+	if !isFile(srcFile) {
+		err = errors.New(srcFile + " is not a file")
+		logger.WithFields(fields).Warn("Failed to store file")
+		return "", err
+	}
+	if err = CreateDirectory(storeRoot); err != nil {
+		logger.WithFields(fields).Warn("Failed to store file")
+		return "", err
+	}
+
+	// Stream the source into a temp file inside the store while hashing so the final rename stays on one device
+	src, err := os.Open(srcFile)
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to store file")
+		return "", err
+	}
+	defer src.Close()
+
+	tmp, err := osTempFile(storeRoot, ".incoming-")
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to store file")
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	hasher := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, hasher), src)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to store file")
+		return "", err
+	}
+
+	checksum := hex.EncodeToString(hasher.Sum(nil))
+	relPath := filepath.Join(checksum[:2], checksum[2:])
+	objectPath := filepath.Join(storeRoot, relPath)
+	fields["object"] = relPath
+
+	if isFile(objectPath) {
+		logger.WithFields(fields).Debug("Object already exists in store")
+		return relPath, nil
+	}
+	if err = CreateDirectory(filepath.Dir(objectPath)); err == nil {
+		if err = os.Chmod(tmp.Name(), fileMode(0)); err == nil {
+			err = os.Rename(tmp.Name(), objectPath)
+		}
+	}
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to store file")
+		return "", err
+	}
+	logger.WithFields(fields).Debug("File stored successfully")
+	return relPath, nil
+}
@@ -0,0 +1,60 @@
+package filesystem
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestStoreByChecksum(t *testing.T) {
+	color.Yellow("Testing content-addressed storage")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var storeRoot = tempDir + "/store"
+	var first = tempDir + "/first.file"
+	var second = tempDir + "/second.file"
+	WriteFile(first, []byte("test"), 0644)
+	WriteFile(second, []byte("test"), 0644)
+
+	firstPath, err := StoreByChecksum(first, storeRoot)
+	if err != nil {
+		t.Error("StoreByChecksum failed:", first, err)
+	}
+	if firstPath != filepath.Join("9f", "86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08") {
+		t.Error("Unexpected object path:", "Got:", firstPath)
+	}
+	secondPath, err := StoreByChecksum(second, storeRoot)
+	if err != nil {
+		t.Error("StoreByChecksum failed:", second, err)
+	}
+	if firstPath != secondPath {
+		t.Error("Identical content stored at different paths:", firstPath, secondPath)
+	}
+	if c, err := LoadFileString(filepath.Join(storeRoot, firstPath)); err != nil || c != "test" {
+		t.Error("Stored object contents don't match:", "Got:", c, "Wanted:", "test")
+	}
+
+	var objects = 0
+	filepath.Walk(storeRoot, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			objects++
+		}
+		return err
+	})
+	if objects != 1 {
+		t.Error("Store should contain a single object:", "Got:", objects)
+	}
+
+	if _, err := StoreByChecksum(tempDir+"/file-dne", storeRoot); err == nil {
+		t.Error("StoreByChecksum succeeded with non-existent file")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
@@ -0,0 +1,169 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SymlinkTarget describes a symlink reported by ListSymlinks
+// Target is as stored in the link (see os.Readlink); Broken is set when it doesn't resolve
+type SymlinkTarget struct {
+	Target string
+	Broken bool
+}
+
+// FindBrokenSymlinks returns the paths of the symlinks under root whose target doesn't resolve
+// Symlinks are reported but never followed
+func FindBrokenSymlinks(root string) ([]string, error) {
+	var err error
+	root, err = BuildAbsolutePathFromHome(root)
+	if err != nil {
+		return nil, err
+	}
+	var fields = logrus.Fields{
+		"root": logPath(root),
+	}
+
+	logger.WithFields(fields).Debug("Finding broken symlinks")
+	var broken = []string{}
+	err = WalkDirectory(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 && isBrokenSymlink(path) {
+			broken = append(broken, path)
+		}
+		return nil
+	})
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to find broken symlinks")
+		return nil, err
+	}
+	fields["count"] = len(broken)
+	logger.WithFields(fields).Debug("Broken symlinks found")
+	return broken, nil
+}
+
+// ListSymlinks returns every symlink under root mapped to its target and whether the target resolves
+// Symlinks are reported but never followed
+func ListSymlinks(root string) (map[string]SymlinkTarget, error) {
+	var err error
+	root, err = BuildAbsolutePathFromHome(root)
+	if err != nil {
+		return nil, err
+	}
+	var fields = logrus.Fields{
+		"root": logPath(root),
+	}
+
+	logger.WithFields(fields).Debug("Listing symlinks")
+	var links = map[string]SymlinkTarget{}
+	err = WalkDirectory(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			return nil
+		}
+		target, err := os.Readlink(path)
+		if err != nil {
+			return err
+		}
+		links[path] = SymlinkTarget{Target: target, Broken: isBrokenSymlink(path)}
+		return nil
+	})
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to list symlinks")
+		return nil, err
+	}
+	fields["count"] = len(links)
+	logger.WithFields(fields).Debug("Symlinks listed")
+	return links, nil
+}
+
+// ResolveSymlinkChain follows path through each symlink hop and returns every target in order
+// Relative targets are resolved against the directory containing the link; the last entry is the final
+// path that isn't a symlink (path itself, if it isn't a symlink)
+// Every directory in a target is resolved on the filesystem rather than lexically, so entries only have a symlink
+// (if any) as their last element, and ".." after a symlinked directory goes where the kernel would take it
+// An *ErrSymlinkLoop is returned if a link is visited twice; if a target doesn't exist, the chain up to that
+// target is returned along with the error
+func ResolveSymlinkChain(path string) ([]string, error) {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return nil, err
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	var fields = logrus.Fields{
+		"path": logPath(path),
+	}
+
+	logger.WithFields(fields).Debug("Resolving symlink chain")
+	var chain = []string{}
+	var visited = map[string]bool{}
+	var current = path
+	for {
+		info, err := os.Lstat(current)
+		if err != nil {
+			logger.WithFields(fields).Warn("Failed to resolve symlink chain")
+			return chain, err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			if len(chain) == 0 {
+				chain = append(chain, current)
+			}
+			break
+		}
+		if visited[current] {
+			err = &ErrSymlinkLoop{Path: current}
+			logger.WithFields(fields).Warn("Failed to resolve symlink chain")
+			return chain, err
+		}
+		visited[current] = true
+
+		target, err := os.Readlink(current)
+		if err != nil {
+			logger.WithFields(fields).Warn("Failed to resolve symlink chain")
+			return chain, err
+		}
+		current, err = resolveLinkTarget(current, target)
+		if err != nil {
+			logger.WithFields(fields).Warn("Failed to resolve symlink chain")
+			return chain, err
+		}
+		chain = append(chain, current)
+	}
+	fields["hops"] = len(chain)
+	logger.WithFields(fields).Debug("Symlink chain resolved")
+	return chain, nil
+}
+
+// resolveLinkTarget returns the path that target, as read from the symlink at link, refers to
+// The directory part is resolved with filepath.EvalSymlinks, leaving only the final element unresolved
+func resolveLinkTarget(link, target string) (string, error) {
+	if !filepath.IsAbs(target) {
+		target = filepath.Dir(link) + string(filepath.Separator) + target
+	}
+	dir, base := filepath.Split(target)
+	if base == "" || base == "." || base == ".." {
+		return filepath.EvalSymlinks(target)
+	}
+	dir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, base), nil
+}
+
+// isBrokenSymlink reports whether the symlink at path can't be resolved, either because its target (or a
+// later hop) doesn't exist or because the links form a loop
+func isBrokenSymlink(path string) bool {
+	_, err := os.Stat(path)
+	return err != nil
+}
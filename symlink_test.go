@@ -0,0 +1,169 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package filesystem
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestResolveSymlinkChain(t *testing.T) {
+	color.Yellow("Testing symlink chain resolution")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+	tempDir, _ = filepath.EvalSymlinks(tempDir)
+
+	var target = tempDir + "/target.file"
+	WriteFile(target, []byte("test"), 0644)
+	CreateDirectory(tempDir + "/sub")
+	os.Symlink("../target.file", tempDir+"/sub/second")
+	os.Symlink(tempDir+"/sub/second", tempDir+"/first")
+
+	var expected = []string{tempDir + "/sub/second", target}
+	if chain, err := ResolveSymlinkChain(tempDir + "/first"); err != nil || !reflect.DeepEqual(chain, expected) {
+		t.Error("ResolveSymlinkChain failed:", "Got:", chain, "Wanted:", expected, err)
+	}
+	if chain, err := ResolveSymlinkChain(target); err != nil || !reflect.DeepEqual(chain, []string{target}) {
+		t.Error("ResolveSymlinkChain failed for a regular file:", "Got:", chain, "Wanted:", []string{target}, err)
+	}
+
+	// ".." after a symlinked directory is resolved from the directory it points to, not lexically
+	CreateDirectory(tempDir + "/real/deep")
+	WriteFile(tempDir+"/real/target.file", []byte("test"), 0644)
+	os.Symlink("../real/deep", tempDir+"/sub/dirlink")
+	os.Symlink("sub/dirlink/../target.file", tempDir+"/dotdot")
+	expected = []string{tempDir + "/real/target.file"}
+	if chain, err := ResolveSymlinkChain(tempDir + "/dotdot"); err != nil || !reflect.DeepEqual(chain, expected) {
+		t.Error("ResolveSymlinkChain failed through a symlinked directory:", "Got:", chain, "Wanted:", expected, err)
+	}
+
+	os.Symlink(tempDir+"/loop-b", tempDir+"/loop-a")
+	os.Symlink(tempDir+"/loop-a", tempDir+"/loop-b")
+	if _, err := ResolveSymlinkChain(tempDir + "/loop-a"); err == nil {
+		t.Error("ResolveSymlinkChain succeeded with a symlink loop")
+	} else if _, ok := err.(*ErrSymlinkLoop); !ok {
+		t.Error("ResolveSymlinkChain should return *ErrSymlinkLoop:", "Got:", err)
+	}
+
+	os.Symlink(tempDir+"/file-dne", tempDir+"/broken")
+	if chain, err := ResolveSymlinkChain(tempDir + "/broken"); !os.IsNotExist(err) || len(chain) != 1 {
+		t.Error("ResolveSymlinkChain should fail on a broken link:", "Got:", chain, err)
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestListSymlinks(t *testing.T) {
+	color.Yellow("Testing symlink listing")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	WriteFile(tempDir+"/target.file", []byte("test"), 0644)
+	CreateDirectory(tempDir + "/sub")
+	os.Symlink("../target.file", tempDir+"/sub/valid")
+	os.Symlink(tempDir+"/missing.file", tempDir+"/broken")
+	os.Symlink("sub", tempDir+"/dir")
+
+	// A valid target can end in text that looks like a marker
+	WriteFile(tempDir+"/target.file (broken)", []byte("test"), 0644)
+	os.Symlink("target.file (broken)", tempDir+"/suffixed")
+
+	var expected = map[string]SymlinkTarget{
+		tempDir + "/sub/valid": {Target: "../target.file"},
+		tempDir + "/broken":    {Target: tempDir + "/missing.file", Broken: true},
+		tempDir + "/dir":       {Target: "sub"},
+		tempDir + "/suffixed":  {Target: "target.file (broken)"},
+	}
+	if links, err := ListSymlinks(tempDir); err != nil || !reflect.DeepEqual(links, expected) {
+		t.Error("Symlinks don't match:", "Got:", links, err, "Wanted:", expected)
+	}
+	if _, err := ListSymlinks(tempDir + "/missing"); err == nil {
+		t.Error("Listing a missing directory should fail")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestFindBrokenSymlinks(t *testing.T) {
+	color.Yellow("Testing broken symlink detection")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var target = tempDir + "/target.file"
+	WriteFile(target, []byte("test"), 0644)
+	WriteFile(tempDir+"/kept.file", []byte("test"), 0644)
+	CreateDirectory(tempDir + "/sub")
+	os.Symlink(target, tempDir+"/sub/link")
+	os.Symlink("../kept.file", tempDir+"/sub/valid")
+
+	if broken, err := FindBrokenSymlinks(tempDir); err != nil || len(broken) != 0 {
+		t.Error("Valid symlinks reported as broken:", "Got:", broken, err, "Wanted:", []string{})
+	}
+	DeleteFile(target)
+	var expected = []string{tempDir + "/sub/link"}
+	if broken, err := FindBrokenSymlinks(tempDir); err != nil || !reflect.DeepEqual(broken, expected) {
+		t.Error("Broken symlinks don't match:", "Got:", broken, err, "Wanted:", expected)
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestSecureJoinSymlinks(t *testing.T) {
+	color.Yellow("Testing secure path joins through symlinks")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var root = tempDir + "/root"
+	CreateDirectory(root + "/docs")
+	WriteFile(tempDir+"/secret", []byte("test"), 0644)
+	os.Symlink("docs", root+"/current")
+	os.Symlink(root+"/docs", root+"/absolute")
+	os.Symlink("../secret", root+"/relative-escape")
+	os.Symlink(tempDir, root+"/absolute-escape")
+	os.Symlink("../..", root+"/docs/up")
+	os.Symlink("loop-b", root+"/loop-a")
+	os.Symlink("loop-a", root+"/loop-b")
+	root, _ = filepath.EvalSymlinks(root)
+
+	var testData = map[string]string{
+		"current/index.html":  root + "/docs/index.html",
+		"absolute/index.html": root + "/docs/index.html",
+		"current/../docs":     root + "/docs",
+	}
+	for path, expected := range testData {
+		if got, err := SecureJoin(root, path); err != nil || got != expected {
+			t.Error("Unexpected join:", path, "Got:", got, err, "Wanted:", expected)
+		}
+	}
+	for _, path := range []string{"relative-escape", "absolute-escape/secret", "docs/up/secret"} {
+		_, err := SecureJoin(root, path)
+		if _, ok := err.(*ErrPathEscape); !ok {
+			t.Error("Symlink escape was not rejected:", path, "Got:", err)
+		}
+	}
+	if _, err := SecureJoin(root, "loop-a/file"); err == nil {
+		t.Error("Symlink loop was not rejected")
+	} else if _, ok := err.(*ErrSymlinkLoop); !ok {
+		t.Error("Unexpected error for symlink loop:", "Got:", err)
+	}
+	color.Yellow("Test Complete")
+	println()
+}
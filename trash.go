@@ -0,0 +1,215 @@
+package filesystem
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var trashDir = "~/.local/share/Trash/files"
+
+// SetTrashDir sets the directory TrashFile moves files into
+// Following the freedesktop.org trash specification, a .trashinfo record for each file is written to the info
+// directory next to it (i.e. ~/.local/share/Trash/info), so desktop trash tools can list and restore it
+// Defaults to ~/.local/share/Trash/files
+func SetTrashDir(path string) {
+	trashDir = path
+}
+
+// RestoreFromTrash moves a file previously trashed with TrashFile back to destination and removes its .trashinfo record
+// An existing file at destination is never overwritten
+func RestoreFromTrash(trashPath, destination string) error {
+	var err error
+	trashPath, err = BuildAbsolutePathFromHome(trashPath)
+	if err != nil {
+		return err
+	}
+	destination, err = BuildAbsolutePathFromHome(destination)
+	if err != nil {
+		return err
+	}
+	var fields = logrus.Fields{
+		"file":        logPath(trashPath),
+		"destination": logPath(destination),
+	}
+
+	logger.WithFields(fields).Debug("Restoring file from trash")
+	if !isFile(trashPath) {
+		err = errors.New(trashPath + " is not a file")
+	} else {
+		err = moveFileNoReplace(trashPath, destination)
+	}
+	if err == nil {
+		// A file trashed elsewhere may not have a record, so a missing one isn't an error
+		if removeErr := os.Remove(trashInfoPath(trashPath)); removeErr != nil && !os.IsNotExist(removeErr) {
+			err = removeErr
+		}
+	}
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to restore file from trash")
+		return err
+	}
+	logger.WithFields(fields).Debug("File restored from trash")
+	return nil
+}
+
+// TrashFile moves the file at path into the trash directory instead of deleting it
+// If a file with the same name is already in the trash, a numbered suffix is added (file (1).txt)
+// Returns the location of the file in the trash
+func TrashFile(path string) (string, error) {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return "", err
+	}
+	dir, err := BuildAbsolutePathFromHome(trashDir)
+	if err != nil {
+		return "", err
+	}
+	var fields = logrus.Fields{
+		"file":  logPath(path),
+		"trash": logPath(dir),
+	}
+
+	logger.WithFields(fields).Debug("Moving file to trash")
+	if !isFile(path) {
+		err = errors.New(path + " is not a file")
+		logger.WithFields(fields).Warn("Failed to move file to trash")
+		return "", err
+	}
+	if err = CreateDirectoryWithMode(dir, 0700); err == nil {
+		err = CreateDirectoryWithMode(filepath.Join(filepath.Dir(dir), "info"), 0700)
+	}
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to move file to trash")
+		return "", err
+	}
+
+	// Each name is claimed by exclusively creating its .trashinfo record first (as the specification requires),
+	// then moved without replacing anything, so concurrent calls never pick the same one
+	var trashPath = filepath.Join(dir, filepath.Base(path))
+	for i := 1; ; i++ {
+		if err = writeTrashInfo(trashPath, path); err == nil {
+			if err = moveFileNoReplace(path, trashPath); err != nil {
+				os.Remove(trashInfoPath(trashPath))
+			}
+		}
+		if !os.IsExist(err) {
+			break
+		}
+		trashPath = numberedPath(filepath.Join(dir, filepath.Base(path)), i)
+	}
+	fields["location"] = logPath(trashPath)
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to move file to trash")
+		return "", err
+	}
+	logger.WithFields(fields).Debug("File moved to trash")
+	return trashPath, nil
+}
+
+// moveFile renames src to dst, replacing dst if it exists
+// Only when src and dst are on different devices is src copied to a temp file beside dst, renamed into place,
+// and removed; every other rename error is returned as-is
+func moveFile(src, dst string) error {
+	err := os.Rename(src, dst)
+	if linkErr, ok := err.(*os.LinkError); ok && linkErr.Err == syscall.EXDEV {
+		return moveFileAcrossDevices(src, dst)
+	}
+	return err
+}
+
+// moveFileAcrossDevices copies src to a temp file in the directory of dst, renames it over dst, then removes src
+// If src can't be removed, the copy at dst is removed again so the file never exists in both places
+func moveFileAcrossDevices(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp-")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	if _, err = copyFile(src, tmp.Name()); err == nil {
+		if err = os.Chmod(tmp.Name(), info.Mode().Perm()); err == nil {
+			err = os.Rename(tmp.Name(), dst)
+		}
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = os.Remove(src); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return nil
+}
+
+// moveFileNoReplace moves src to dst, failing with an error satisfying os.IsExist if dst already exists
+// src is hard linked to dst (which fails if dst exists) and then removed; where links aren't possible (i.e. across
+// devices), dst is first claimed by creating it exclusively, then replaced by moveFile
+func moveFileNoReplace(src, dst string) error {
+	err := os.Link(src, dst)
+	if err == nil {
+		if err = os.Remove(src); err != nil {
+			os.Remove(dst)
+		}
+		return err
+	}
+	if os.IsExist(err) {
+		return err
+	}
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	f.Close()
+	if err = moveFile(src, dst); err != nil {
+		os.Remove(dst)
+	}
+	return err
+}
+
+// trashInfoPath returns where the .trashinfo record for the file at trashPath is kept
+func trashInfoPath(trashPath string) string {
+	return filepath.Join(filepath.Dir(filepath.Dir(trashPath)), "info", filepath.Base(trashPath)+".trashinfo")
+}
+
+// writeTrashInfo exclusively creates the .trashinfo record for trashPath, recording original as its location
+// Fails with an error satisfying os.IsExist if the record already exists
+func writeTrashInfo(trashPath, original string) error {
+	f, err := os.OpenFile(trashInfoPath(trashPath), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	var info = "[Trash Info]\n" +
+		"Path=" + (&url.URL{Path: original}).EscapedPath() + "\n" +
+		"DeletionDate=" + time.Now().Format("2006-01-02T15:04:05") + "\n"
+	_, err = f.WriteString(info)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// numberedPath inserts a counter before the extension of path (i.e. file.txt => file (1).txt)
+func numberedPath(path string, n int) string {
+	var ext = GetFileExtension(path)
+	if ext != "" {
+		ext = "." + ext
+	}
+	return strings.TrimSuffix(path, ext) + " (" + strconv.Itoa(n) + ")" + ext
+}
@@ -0,0 +1,176 @@
+package filesystem
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestTrashFile(t *testing.T) {
+	color.Yellow("Testing trash functionality")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+	SetTrashDir(tempDir + "/trash/files")
+	defer SetTrashDir("~/.local/share/Trash/files")
+
+	var testFile = tempDir + "/test.file"
+	WriteFile(testFile, []byte("first"), 0644)
+	first, err := TrashFile(testFile)
+	if err != nil {
+		t.Fatal("TrashFile failed:", testFile, err)
+	}
+	if CheckExists(testFile) {
+		t.Error("Trashed file still exists at original location:", testFile)
+	}
+	if first != tempDir+"/trash/files/test.file" {
+		t.Error("Unexpected trash location:", "Got:", first, "Wanted:", tempDir+"/trash/files/test.file")
+	}
+	var info = tempDir + "/trash/info/test.file.trashinfo"
+	if c, err := LoadFileString(info); err != nil || !strings.HasPrefix(c, "[Trash Info]\nPath="+testFile+"\nDeletionDate=") {
+		t.Error("Trash info record doesn't match:", "Got:", c, err)
+	}
+
+	WriteFile(testFile, []byte("second"), 0644)
+	second, err := TrashFile(testFile)
+	if err != nil {
+		t.Fatal("TrashFile failed:", testFile, err)
+	}
+	if second != tempDir+"/trash/files/test (1).file" {
+		t.Error("Unexpected trash location for colliding name:", "Got:", second, "Wanted:", tempDir+"/trash/files/test (1).file")
+	}
+	if c, err := LoadFileString(first); err != nil || c != "first" {
+		t.Error("Trashed file contents don't match:", "Got:", c, "Wanted:", "first")
+	}
+
+	if err := RestoreFromTrash(second, testFile); err != nil {
+		t.Error("RestoreFromTrash failed:", second, err)
+	}
+	if c, err := LoadFileString(testFile); err != nil || c != "second" {
+		t.Error("Restored file contents don't match:", "Got:", c, "Wanted:", "second")
+	}
+	if CheckExists(tempDir + "/trash/info/test (1).file.trashinfo") {
+		t.Error("RestoreFromTrash left the trash info record behind")
+	}
+	if err := RestoreFromTrash(first, testFile); err == nil {
+		t.Error("RestoreFromTrash should not overwrite an existing file")
+	}
+
+	if _, err := TrashFile(tempDir + "/file-dne"); err == nil {
+		t.Error("TrashFile succeeded with non-existent file")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestTrashFileConcurrent(t *testing.T) {
+	color.Yellow("Testing concurrent trash moves")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+	SetTrashDir(tempDir + "/trash/files")
+	defer SetTrashDir("~/.local/share/Trash/files")
+
+	// Files with the same name from different directories must each get their own trash location
+	const files = 20
+	var locations = make(chan string, files)
+	var wg sync.WaitGroup
+	for i := 0; i < files; i++ {
+		var dir = tempDir + "/" + strconv.Itoa(i)
+		CreateDirectory(dir)
+		WriteFile(dir+"/test.file", []byte(strconv.Itoa(i)), 0644)
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			location, err := TrashFile(path)
+			if err != nil {
+				t.Error("TrashFile failed:", path, err)
+			}
+			locations <- location
+		}(dir + "/test.file")
+	}
+	wg.Wait()
+	close(locations)
+
+	var seen = map[string]bool{}
+	for location := range locations {
+		if seen[location] {
+			t.Error("Two files were trashed to the same location:", location)
+		}
+		seen[location] = true
+	}
+	if contents, _ := GetDirectoryContents(tempDir + "/trash/files"); len(contents) != files {
+		t.Error("Trashed files were lost:", "Got:", len(contents), "Wanted:", files)
+	}
+	if contents, _ := GetDirectoryContents(tempDir + "/trash/info"); len(contents) != files {
+		t.Error("Trash info records don't match the trashed files:", "Got:", len(contents), "Wanted:", files)
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestMoveFile(t *testing.T) {
+	color.Yellow("Testing file moves")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var src = tempDir + "/src.file"
+	WriteFile(src, []byte("test"), 0640)
+
+	// Errors other than crossing devices are returned without copying anything
+	CreateDirectory(tempDir + "/dir")
+	if err = moveFile(src, tempDir+"/dir"); err == nil {
+		t.Error("moveFile replaced a directory with a file")
+	}
+	if err = moveFile(src, tempDir+"/dir-dne/dst.file"); err == nil {
+		t.Error("moveFile succeeded into a missing directory")
+	}
+	if c, err := LoadFileString(src); err != nil || c != "test" {
+		t.Error("Failed move modified the source:", "Got:", c, err, "Wanted:", "test")
+	}
+
+	// Moves never replace an existing file when asked not to
+	var dst = tempDir + "/dst.file"
+	WriteFile(dst, []byte("existing"), 0644)
+	if err = moveFileNoReplace(src, dst); !os.IsExist(err) {
+		t.Error("moveFileNoReplace should refuse to replace a file:", "Got:", err)
+	}
+	if c, _ := LoadFileString(dst); c != "existing" {
+		t.Error("moveFileNoReplace replaced an existing file:", "Got:", c, "Wanted:", "existing")
+	}
+
+	// Copy across devices when a second filesystem is available
+	if shm, err := ioutil.TempDir("/dev/shm", ".filesystem-test-"); err == nil {
+		defer RemoveDirectory(shm, true)
+		var remote = shm + "/moved.file"
+		if err = moveFile(src, remote); err != nil {
+			t.Error("moveFile failed across devices:", remote, err)
+		}
+		if CheckExists(src) {
+			t.Error("moveFile left the source behind after copying across devices")
+		}
+		if stat, err := os.Stat(remote); err != nil || stat.Mode().Perm() != 0640 {
+			t.Error("File moved across devices has unexpected mode:", "Got:", stat, err, "Wanted:", os.FileMode(0640))
+		}
+		if c, _ := LoadFileString(remote); c != "test" {
+			t.Error("File moved across devices has unexpected contents:", "Got:", c, "Wanted:", "test")
+		}
+		if contents, _ := GetDirectoryContents(shm); len(contents) != 1 {
+			t.Error("moveFile left temp files behind:", contents)
+		}
+	}
+	color.Yellow("Test Complete")
+	println()
+}
@@ -0,0 +1,85 @@
+package filesystem
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ReadBool reads a single boolean value from path (i.e. a sysfs attribute)
+// Surrounding whitespace is ignored; anything strconv.ParseBool accepts is valid, as are y/n, yes/no, and
+// on/off in any case.  Malformed contents return an *ErrParseValue
+func ReadBool(path string) (bool, error) {
+	var value bool
+	err := readValue(path, "bool", func(s string) error {
+		switch strings.ToLower(s) {
+		case "y", "yes", "on":
+			value = true
+			return nil
+		case "n", "no", "off":
+			value = false
+			return nil
+		}
+		var err error
+		value, err = strconv.ParseBool(s)
+		return err
+	})
+	return value, err
+}
+
+// ReadFloat reads a single floating point value from path (i.e. a sysfs attribute)
+// Surrounding whitespace is ignored; malformed contents return an *ErrParseValue
+func ReadFloat(path string) (float64, error) {
+	var value float64
+	err := readValue(path, "float", func(s string) error {
+		var err error
+		value, err = strconv.ParseFloat(s, 64)
+		return err
+	})
+	return value, err
+}
+
+// ReadInt reads a single base 10 integer value from path (i.e. a sysfs attribute)
+// Surrounding whitespace is ignored; malformed contents return an *ErrParseValue
+func ReadInt(path string) (int64, error) {
+	var value int64
+	err := readValue(path, "int", func(s string) error {
+		var err error
+		value, err = strconv.ParseInt(s, 10, 64)
+		return err
+	})
+	return value, err
+}
+
+// readValue loads path and passes its trimmed contents to parse, wrapping any parse error in an *ErrParseValue
+func readValue(path, kind string, parse func(s string) error) error {
+	var err error
+	path, err = BuildAbsolutePathFromHome(path)
+	if err != nil {
+		return err
+	}
+	var fields = logrus.Fields{
+		"file": logPath(path),
+		"type": kind,
+	}
+
+	logger.WithFields(fields).Debug("Reading value from file")
+	contents, err := LoadFileBytes(path)
+	if err != nil {
+		logger.WithFields(fields).Info("Could not read file")
+		return err
+	}
+	var s = strings.TrimSpace(string(contents))
+	if s == "" {
+		err = errors.New("file is empty")
+	} else {
+		err = parse(s)
+	}
+	if err != nil {
+		logger.WithFields(fields).Info("Could not parse value")
+		return &ErrParseValue{Path: path, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package filesystem
+
+import (
+	"errors"
+	"io/ioutil"
+	"strconv"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestReadValues(t *testing.T) {
+	color.Yellow("Testing typed value reads")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var testFile = tempDir + "/value"
+	var parseError = func(err error) bool {
+		var parseErr *ErrParseValue
+		return errors.As(err, &parseErr) && parseErr.Path == testFile
+	}
+
+	var ints = map[string]int64{"42\n": 42, "  -7  ": -7, "0": 0}
+	for contents, expected := range ints {
+		WriteFile(testFile, []byte(contents), 0644)
+		if value, err := ReadInt(testFile); err != nil || value != expected {
+			t.Error("Unexpected int:", strconv.Quote(contents), "Got:", value, err, "Wanted:", expected)
+		}
+	}
+	for _, contents := range []string{"", "4.2", "forty-two", "99999999999999999999"} {
+		WriteFile(testFile, []byte(contents), 0644)
+		if _, err := ReadInt(testFile); !parseError(err) {
+			t.Error("Malformed int was not rejected:", strconv.Quote(contents), "Got:", err)
+		}
+	}
+
+	var floats = map[string]float64{"3.25\n": 3.25, "-1e3": -1000, "7": 7}
+	for contents, expected := range floats {
+		WriteFile(testFile, []byte(contents), 0644)
+		if value, err := ReadFloat(testFile); err != nil || value != expected {
+			t.Error("Unexpected float:", strconv.Quote(contents), "Got:", value, err, "Wanted:", expected)
+		}
+	}
+	for _, contents := range []string{"", "1.2.3", "pi"} {
+		WriteFile(testFile, []byte(contents), 0644)
+		if _, err := ReadFloat(testFile); !parseError(err) {
+			t.Error("Malformed float was not rejected:", strconv.Quote(contents), "Got:", err)
+		}
+	}
+
+	var bools = map[string]bool{"1\n": true, "0\n": false, "true": true, "False": false, "Y\n": true, "N": false, "on": true, "off": false}
+	for contents, expected := range bools {
+		WriteFile(testFile, []byte(contents), 0644)
+		if value, err := ReadBool(testFile); err != nil || value != expected {
+			t.Error("Unexpected bool:", strconv.Quote(contents), "Got:", value, err, "Wanted:", expected)
+		}
+	}
+	for _, contents := range []string{"", "2", "maybe"} {
+		WriteFile(testFile, []byte(contents), 0644)
+		if _, err := ReadBool(testFile); !parseError(err) {
+			t.Error("Malformed bool was not rejected:", strconv.Quote(contents), "Got:", err)
+		}
+	}
+
+	// The underlying strconv error is still available
+	WriteFile(testFile, []byte("99999999999999999999"), 0644)
+	if _, err := ReadInt(testFile); !errors.Is(err, strconv.ErrRange) {
+		t.Error("Parse error does not wrap the strconv error:", "Got:", err)
+	}
+	if _, err := ReadInt(tempDir + "/missing"); err == nil || parseError(err) {
+		t.Error("Missing file should fail with a read error:", "Got:", err)
+	}
+	color.Yellow("Test Complete")
+	println()
+}
@@ -0,0 +1,181 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ExtensionHistogram counts the files under root by extension (see GetFileExtension)
+// Files without an extension are counted under the empty string; directories are not counted
+// When recursive is false, only the entries directly inside root are counted
+func ExtensionHistogram(root string, recursive bool) (map[string]int, error) {
+	var err error
+	root, err = BuildAbsolutePathFromHome(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var histogram = map[string]int{}
+	err = WalkDirectory(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if !recursive && path != root {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		histogram[GetFileExtension(path)]++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return histogram, nil
+}
+
+// ListRegularFiles returns the paths of the regular files under root
+// Symlinks and special files (devices, sockets, FIFOs) are excluded, so every returned path is safe to read
+// without blocking or following links
+func ListRegularFiles(root string) ([]string, error) {
+	var files = []string{}
+	err := WalkDirectory(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// RemoveFilesOlderThan removes the regular files in dir last modified more than age ago and returns their paths
+// Directories are never removed; when recursive is false, only the files directly inside dir are considered
+// If a removal fails, the files removed so far are returned along with the error
+func RemoveFilesOlderThan(dir string, age time.Duration, recursive bool) (removed []string, err error) {
+	dir, err = BuildAbsolutePathFromHome(dir)
+	if err != nil {
+		return nil, err
+	}
+	var fields = logrus.Fields{
+		"directory": logPath(dir),
+		"age":       age,
+	}
+
+	logger.WithFields(fields).Debug("Removing old files")
+	var cutoff = time.Now().Add(-age)
+	var expired = []string{}
+	err = WalkDirectory(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if !recursive && path != dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Mode().IsRegular() && info.ModTime().Before(cutoff) {
+			expired = append(expired, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	removed = []string{}
+	for _, path := range expired {
+		if err = os.Remove(path); err != nil {
+			logger.WithFields(fields).Warn("Failed to remove old files")
+			return removed, err
+		}
+		removed = append(removed, path)
+	}
+	fields["removed"] = len(removed)
+	logger.WithFields(fields).Debug("Old files removed")
+	return removed, nil
+}
+
+// SizeByExtension totals the sizes of the regular files under root by extension (see GetFileExtension)
+// Files without an extension are totalled under the empty string; symlinks and special files are skipped
+// When recursive is false, only the entries directly inside root are totalled
+func SizeByExtension(root string, recursive bool) (map[string]int64, error) {
+	var err error
+	root, err = BuildAbsolutePathFromHome(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var sizes = map[string]int64{}
+	err = WalkDirectory(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if !recursive && path != root {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Mode().IsRegular() {
+			sizes[GetFileExtension(path)] += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sizes, nil
+}
+
+// WalkDirectory walks the file tree rooted at root, calling walkFn for each entry (see filepath.Walk)
+// supports ~ expansion
+func WalkDirectory(root string, walkFn filepath.WalkFunc) error {
+	return WalkDirectoryMaxDepth(root, 0, walkFn)
+}
+
+// WalkDirectoryMaxDepth walks the file tree rooted at root like WalkDirectory, descending at most maxWalkDepth
+// levels below it
+// root is depth 0 and its entries are depth 1; directories at the maximum depth are visited but not descended into
+// A depth of 0 is unlimited
+func WalkDirectoryMaxDepth(root string, maxWalkDepth int, walkFn filepath.WalkFunc) error {
+	var err error
+	root, err = BuildAbsolutePathFromHome(root)
+	if err != nil {
+		return err
+	}
+	var fields = logrus.Fields{
+		"directory": logPath(root),
+		"maxDepth":  maxWalkDepth,
+	}
+
+	logger.WithFields(fields).Debug("Walking directory")
+	defer logDuration(fields, time.Now(), "Walk")
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if walkErr := walkFn(path, info, err); walkErr != nil || maxWalkDepth <= 0 || info == nil || !info.IsDir() {
+			return walkErr
+		}
+		if depth, _ := pathDepth(root, path); depth >= maxWalkDepth {
+			logger.WithFields(logrus.Fields{
+				"directory": logPath(path),
+				"maxDepth":  maxWalkDepth,
+			}).Debug("Skipping directory contents beyond maximum walk depth")
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to walk directory")
+	}
+	return err
+}
@@ -0,0 +1,166 @@
+package filesystem
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+func TestWalkDirectory(t *testing.T) {
+	color.Yellow("Testing directory walks")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	CreateDirectory(tempDir + "/1/2/3/4/5")
+	WriteFile(tempDir+"/1/file", []byte("test"), 0644)
+	WriteFile(tempDir+"/1/2/3/file", []byte("test"), 0644)
+
+	var walk = func(maxDepth int) []string {
+		var visited = []string{}
+		err := WalkDirectoryMaxDepth(tempDir, maxDepth, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			relPath, _ := filepath.Rel(tempDir, path)
+			visited = append(visited, relPath)
+			return nil
+		})
+		if err != nil {
+			t.Error("WalkDirectory failed:", tempDir, err)
+		}
+		sort.Strings(visited)
+		return visited
+	}
+
+	var unlimited = walk(0)
+	if len(unlimited) != 8 {
+		t.Error("Unlimited walk visited wrong number of entries:", "Got:", unlimited)
+	}
+	var visited = 0
+	WalkDirectory(tempDir, func(path string, info os.FileInfo, err error) error {
+		visited++
+		return err
+	})
+	if visited != len(unlimited) {
+		t.Error("WalkDirectory visited wrong number of entries:", "Got:", visited, "Wanted:", len(unlimited))
+	}
+
+	var limited = walk(2)
+	var wanted = []string{".", "1", "1/2", "1/file"}
+	if !reflect.DeepEqual(limited, wanted) {
+		t.Error("Depth-limited walk visited incorrect entries:", "Got:", limited, "Wanted:", wanted)
+	}
+
+	if err := WalkDirectory(tempDir+"/dne", func(path string, info os.FileInfo, err error) error { return err }); err == nil {
+		t.Error("WalkDirectory succeeded with non-existent directory")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestExtensionHistogram(t *testing.T) {
+	color.Yellow("Testing extension histograms")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	CreateDirectory(tempDir + "/sub.d")
+	for _, name := range []string{"a.txt", "b.txt", "c.go", "Makefile", "sub.d/d.txt", "sub.d/e.tar.gz", "sub.d/README"} {
+		WriteFile(tempDir+"/"+name, []byte("test"), 0644)
+	}
+
+	histogram, err := ExtensionHistogram(tempDir, true)
+	if expected := map[string]int{"txt": 3, "go": 1, "gz": 1, "": 2}; err != nil || !reflect.DeepEqual(histogram, expected) {
+		t.Error("Unexpected recursive histogram:", "Got:", histogram, "Wanted:", expected, err)
+	}
+	histogram, err = ExtensionHistogram(tempDir, false)
+	if expected := map[string]int{"txt": 2, "go": 1, "": 1}; err != nil || !reflect.DeepEqual(histogram, expected) {
+		t.Error("Unexpected histogram:", "Got:", histogram, "Wanted:", expected, err)
+	}
+	if _, err := ExtensionHistogram(tempDir+"/dir-dne", true); err == nil {
+		t.Error("ExtensionHistogram succeeded with non-existent directory")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestSizeByExtension(t *testing.T) {
+	color.Yellow("Testing per-extension sizes")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	CreateDirectory(tempDir + "/sub")
+	var testData = map[string]int{"a.log": 100, "b.log": 250, "c.json": 10, "sub/d.log": 1000, "sub/e.json": 5}
+	for name, size := range testData {
+		WriteFile(tempDir+"/"+name, make([]byte, size), 0644)
+	}
+
+	sizes, err := SizeByExtension(tempDir, true)
+	if expected := map[string]int64{"log": 1350, "json": 15}; err != nil || !reflect.DeepEqual(sizes, expected) {
+		t.Error("Unexpected recursive sizes:", "Got:", sizes, "Wanted:", expected, err)
+	}
+	sizes, err = SizeByExtension(tempDir, false)
+	if expected := map[string]int64{"log": 350, "json": 10}; err != nil || !reflect.DeepEqual(sizes, expected) {
+		t.Error("Unexpected sizes:", "Got:", sizes, "Wanted:", expected, err)
+	}
+	if _, err := SizeByExtension(tempDir+"/dir-dne", true); err == nil {
+		t.Error("SizeByExtension succeeded with non-existent directory")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
+
+func TestRemoveFilesOlderThan(t *testing.T) {
+	color.Yellow("Testing removal of old files")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var old = time.Now().Add(-48 * time.Hour)
+	CreateDirectory(tempDir + "/sub")
+	for _, name := range []string{"old.log", "new.log", "sub/old.log", "sub/new.log"} {
+		WriteFile(tempDir+"/"+name, []byte("test"), 0644)
+	}
+	for _, name := range []string{"old.log", "sub/old.log", "sub"} {
+		os.Chtimes(tempDir+"/"+name, old, old)
+	}
+
+	removed, err := RemoveFilesOlderThan(tempDir, 24*time.Hour, false)
+	if expected := []string{tempDir + "/old.log"}; err != nil || !reflect.DeepEqual(removed, expected) {
+		t.Error("Unexpected files removed:", "Got:", removed, "Wanted:", expected, err)
+	}
+	if !CheckExists(tempDir + "/sub/old.log") {
+		t.Error("Non-recursive removal descended into a subdirectory")
+	}
+
+	removed, err = RemoveFilesOlderThan(tempDir, 24*time.Hour, true)
+	if expected := []string{tempDir + "/sub/old.log"}; err != nil || !reflect.DeepEqual(removed, expected) {
+		t.Error("Unexpected files removed:", "Got:", removed, "Wanted:", expected, err)
+	}
+	for _, name := range []string{"new.log", "sub/new.log", "sub"} {
+		if !CheckExists(tempDir + "/" + name) {
+			t.Error("RemoveFilesOlderThan removed a file that isn't old enough:", name)
+		}
+	}
+	if _, err := RemoveFilesOlderThan(tempDir+"/dir-dne", time.Hour, true); err == nil {
+		t.Error("RemoveFilesOlderThan succeeded with non-existent directory")
+	}
+	color.Yellow("Test Complete")
+	println()
+}
@@ -0,0 +1,131 @@
+package filesystem
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// EventOp describes the kind of change reported in a FilesystemEvent
+type EventOp int
+
+// Changes reported by WatchRecursive
+const (
+	EventCreate EventOp = iota + 1
+	EventWrite
+	EventRemove
+)
+
+func (op EventOp) String() string {
+	switch op {
+	case EventCreate:
+		return "create"
+	case EventWrite:
+		return "write"
+	case EventRemove:
+		return "remove"
+	}
+	return "unknown"
+}
+
+// FilesystemEvent is a change to a single path under a watched directory
+type FilesystemEvent struct {
+	Path string
+	Op   EventOp
+}
+
+// defaultWatchPollInterval is how often WatchRecursive checks for changes when no interval is given
+const defaultWatchPollInterval = 100 * time.Millisecond
+
+// recursiveWatcher stops a WatchRecursive poller when closed
+type recursiveWatcher struct {
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// Close stops the watch and waits for it to finish; no events are sent after Close returns
+func (w *recursiveWatcher) Close() error {
+	w.once.Do(func() {
+		close(w.stop)
+	})
+	<-w.done
+	return nil
+}
+
+// WatchRecursive sends an event to events for each file or directory created, written, or removed anywhere under root
+// until the returned Closer is closed; directories created after the watch starts are watched as well
+// Changes are detected by comparing snapshots (see SnapshotDirectory) every pollInterval (100ms if 0) rather than
+// through fsnotify: the package only depends on logrus and go-homedir, polling behaves the same on every platform
+// and on network mounts that don't deliver notifications, and it isn't bounded by per-user watch limits
+// (fs.inotify.max_user_watches) on large trees.  Each poll stats every entry under root, so choose pollInterval
+// with the size of the tree in mind
+// Changes that are undone within one interval aren't reported and repeated writes within one interval are reported
+// once. Events within a poll are ordered creates (parents before children), then writes, then removes; sending
+// blocks until the event is received or the watch is closed
+func WatchRecursive(root string, events chan<- FilesystemEvent, pollInterval time.Duration) (io.Closer, error) {
+	var err error
+	root, err = BuildAbsolutePathFromHome(root)
+	if err != nil {
+		return nil, err
+	}
+	if pollInterval <= 0 {
+		pollInterval = defaultWatchPollInterval
+	}
+	var fields = logrus.Fields{
+		"directory": logPath(root),
+		"interval":  pollInterval,
+	}
+
+	logger.WithFields(fields).Debug("Starting recursive watch")
+	if events == nil {
+		return nil, errors.New("no events channel provided")
+	}
+	previous, err := SnapshotDirectory(root)
+	if err != nil {
+		logger.WithFields(fields).Warn("Failed to start recursive watch")
+		return nil, err
+	}
+
+	var watcher = &recursiveWatcher{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(watcher.done)
+		var ticker = time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-watcher.stop:
+				return
+			case <-ticker.C:
+			}
+			current, err := SnapshotDirectory(root)
+			if err != nil {
+				// Entries can disappear mid-walk; try again on the next poll
+				logger.WithFields(fields).Debug("Failed to snapshot watched directory")
+				continue
+			}
+			added, removed, changed := DiffSnapshots(previous, current)
+			previous = current
+			for _, batch := range []struct {
+				paths []string
+				op    EventOp
+			}{{added, EventCreate}, {changed, EventWrite}, {removed, EventRemove}} {
+				for _, relPath := range batch.paths {
+					select {
+					case events <- FilesystemEvent{filepath.Join(root, relPath), batch.op}:
+					case <-watcher.stop:
+						return
+					}
+				}
+			}
+		}
+	}()
+	return watcher, nil
+}
@@ -0,0 +1,64 @@
+package filesystem
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+func TestWatchRecursive(t *testing.T) {
+	color.Yellow("Testing recursive directory watches")
+	var tempDir, err = ioutil.TempDir("/tmp/", ".filesystem-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveDirectory(tempDir, true)
+
+	var events = make(chan FilesystemEvent, 16)
+	watcher, err := WatchRecursive(tempDir, events, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal("WatchRecursive failed:", tempDir, err)
+	}
+	defer watcher.Close()
+
+	var expect = func(path string, op EventOp) {
+		var timeout = time.After(2 * time.Second)
+		for {
+			select {
+			case e := <-events:
+				if e.Path == path && e.Op == op {
+					return
+				}
+			case <-timeout:
+				t.Error("Event was not delivered:", path, op)
+				return
+			}
+		}
+	}
+
+	CreateDirectory(tempDir + "/a/b")
+	expect(tempDir+"/a/b", EventCreate)
+	WriteFile(tempDir+"/a/b/test.file", []byte("test"), 0644)
+	expect(tempDir+"/a/b/test.file", EventCreate)
+	WriteFile(tempDir+"/a/b/test.file", []byte("longer test"), 0644)
+	expect(tempDir+"/a/b/test.file", EventWrite)
+	DeleteFile(tempDir + "/a/b/test.file")
+	expect(tempDir+"/a/b/test.file", EventRemove)
+
+	watcher.Close()
+	WriteFile(tempDir+"/after.file", []byte("test"), 0644)
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case e := <-events:
+		t.Error("Event delivered after the watch was closed:", e)
+	default:
+	}
+
+	if _, err := WatchRecursive(tempDir+"/dir-dne", events, 0); err == nil {
+		t.Error("WatchRecursive succeeded with non-existent directory")
+	}
+	color.Yellow("Test Complete")
+	println()
+}